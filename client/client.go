@@ -1,14 +1,34 @@
 package main
 
 import (
+	"flag"
+	"os"
+
 	iperf "github.com/linimbus/iperf-windows/iperf3"
 )
 
 func main() {
+	headless := flag.Bool("headless", false, "run the client test without window, exit code is the threshold verdict")
+	junit := flag.String("junit", "", "write the headless test result as JUnit XML to the file")
+	compare := flag.String("compare", "", "compare two saved iperf3 json files or history ids, separated by comma, the diff is saved in the client log directory")
+	flag.Parse()
+
 	NAME := "client"
 	iperf.FileInit(NAME)
 	iperf.LogInit(NAME)
-	iperf.IconInit()
 	iperf.ConfigInit(NAME)
+
+	if *compare != "" {
+		os.Exit(iperf.ClientCompare(*compare))
+	}
+
+	iperf.MetricsInit()
+	iperf.InfluxInit()
+
+	if *headless {
+		os.Exit(iperf.ClientHeadless(*junit))
+	}
+
+	iperf.IconInit()
 	iperf.ClientWindows()
 }
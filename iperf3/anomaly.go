@@ -0,0 +1,220 @@
+package iperf3
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/astaxie/beego/logs"
+)
+
+const (
+	AnomalyDip             = "dip"
+	AnomalyStall           = "stall"
+	AnomalyRetransmitBurst = "retransmit_burst"
+	AnomalySawtooth        = "sawtooth"
+)
+
+const (
+	retransmitBurstMinimum = 10
+	sawtoothMinIntervals   = 6
+	sawtoothAmplitude      = 0.3
+)
+
+type Anomaly struct {
+	Kind          string  `json:"kind"`
+	Direction     string  `json:"direction"`
+	Start         float64 `json:"start"`
+	End           float64 `json:"end"`
+	Time          string  `json:"time,omitempty"`
+	BitsPerSecond float64 `json:"bits_per_second"`
+	Median        float64 `json:"median_bits_per_second"`
+	Retransmits   int64   `json:"retransmits,omitempty"`
+	Message       string  `json:"message"`
+}
+
+type anomalyDetector struct {
+	result      *Result
+	direction   string
+	sums        []Sum
+	median      float64
+	retrMedian  float64
+	dipFraction float64
+	burstFactor float64
+	anomalies   []Anomaly
+}
+
+func medianOf(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	return percentile(sorted, 0.5)
+}
+
+func (d *anomalyDetector) add(kind string, first, last int, message string) {
+	var bytes int64
+	var retransmits int64
+	for i := first; i <= last; i++ {
+		bytes += d.sums[i].Bytes
+		retransmits += d.sums[i].Retransmits
+	}
+	start, end := d.sums[first].Start, d.sums[last].End
+	rate := 0.0
+	if end > start {
+		rate = float64(bytes) * 8 / (end - start)
+	}
+	d.anomalies = append(d.anomalies, Anomaly{
+		Kind:          kind,
+		Direction:     d.direction,
+		Start:         start,
+		End:           end,
+		Time:          resultTimestamp(d.result, start),
+		BitsPerSecond: rate,
+		Median:        d.median,
+		Retransmits:   retransmits,
+		Message:       fmt.Sprintf("%s %s %.1f-%.1fs %s", d.direction, kind, start, end, message),
+	})
+}
+
+func (d *anomalyDetector) spans(match func(sum *Sum) bool, found func(first, last int)) {
+	first := -1
+	for i := range d.sums {
+		if match(&d.sums[i]) {
+			if first < 0 {
+				first = i
+			}
+			continue
+		}
+		if first >= 0 {
+			found(first, i-1)
+			first = -1
+		}
+	}
+	if first >= 0 {
+		found(first, len(d.sums)-1)
+	}
+}
+
+func (d *anomalyDetector) detectStalls() {
+	d.spans(func(sum *Sum) bool {
+		return sum.Bytes == 0
+	}, func(first, last int) {
+		d.add(AnomalyStall, first, last, "zero bytes transferred")
+	})
+}
+
+func (d *anomalyDetector) detectDips() {
+	limit := d.median * d.dipFraction
+	d.spans(func(sum *Sum) bool {
+		return sum.Bytes > 0 && sum.BitPerSecond < limit
+	}, func(first, last int) {
+		d.add(AnomalyDip, first, last, fmt.Sprintf("throughput below %.0f%% of median %s",
+			d.dipFraction*100, BitRateView(d.median)))
+	})
+}
+
+func (d *anomalyDetector) detectRetransmitBursts() {
+	limit := math.Max(d.retrMedian*d.burstFactor, retransmitBurstMinimum)
+	d.spans(func(sum *Sum) bool {
+		return float64(sum.Retransmits) > limit
+	}, func(first, last int) {
+		d.add(AnomalyRetransmitBurst, first, last, fmt.Sprintf("retransmits above %.0f per interval", limit))
+	})
+}
+
+func (d *anomalyDetector) detectSawtooth() {
+	if d.median == 0 || len(d.sums) < sawtoothMinIntervals {
+		return
+	}
+
+	first, last := -1, -1
+	prevSign := 0
+	flush := func() {
+		if first >= 0 && last-first+1 >= sawtoothMinIntervals {
+			d.add(AnomalySawtooth, first, last, "throughput oscillates up and down")
+		}
+		first, last = -1, -1
+	}
+
+	for i := 1; i < len(d.sums); i++ {
+		delta := d.sums[i].BitPerSecond - d.sums[i-1].BitPerSecond
+		sign := 0
+		if math.Abs(delta) >= d.median*sawtoothAmplitude {
+			if delta > 0 {
+				sign = 1
+			} else {
+				sign = -1
+			}
+		}
+		if sign != 0 && sign == -prevSign {
+			if first < 0 {
+				first = i - 2
+			}
+			last = i
+		} else {
+			flush()
+		}
+		prevSign = sign
+	}
+	flush()
+}
+
+func DetectAnomalies(result *Result, dipFraction, burstFactor float64) []Anomaly {
+	anomalies := make([]Anomaly, 0)
+
+	for _, direction := range []string{DirectionUpload, DirectionDownload} {
+		detector := &anomalyDetector{
+			result:      result,
+			direction:   direction,
+			sums:        make([]Sum, 0),
+			dipFraction: dipFraction,
+			burstFactor: burstFactor,
+		}
+
+		rates := make([]float64, 0)
+		retransmits := make([]float64, 0)
+		for i := range result.Intervals {
+			sum, ok := result.IntervalSum(&result.Intervals[i], direction)
+			if !ok || sum.Omitted || sum.End <= sum.Start {
+				continue
+			}
+			detector.sums = append(detector.sums, sum)
+			rates = append(rates, sum.BitPerSecond)
+			retransmits = append(retransmits, float64(sum.Retransmits))
+		}
+		if len(detector.sums) == 0 {
+			continue
+		}
+
+		detector.median = medianOf(rates)
+		detector.retrMedian = medianOf(retransmits)
+
+		detector.detectStalls()
+		detector.detectDips()
+		if !result.IsUDP() {
+			detector.detectRetransmitBursts()
+		}
+		detector.detectSawtooth()
+
+		anomalies = append(anomalies, detector.anomalies...)
+	}
+
+	sort.SliceStable(anomalies, func(i, j int) bool {
+		return anomalies[i].Start < anomalies[j].Start
+	})
+
+	return anomalies
+}
+
+func anomalyEvaluate(config Config, summary *RunSummary) {
+	if summary == nil || summary.Result() == nil || summary.Failed() {
+		return
+	}
+
+	summary.Anomalies = DetectAnomalies(summary.Result(), config.AnomalyDipFraction, config.AnomalyRetransmitBurst)
+	for _, anomaly := range summary.Anomalies {
+		logs.Warning("iperf3 anomaly %s", anomaly.Message)
+	}
+}
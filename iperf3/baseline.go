@@ -0,0 +1,189 @@
+package iperf3
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+)
+
+const (
+	VerdictPass      = "pass"
+	VerdictRegressed = "regressed"
+	VerdictImproved  = "improved"
+)
+
+type Baseline struct {
+	Target  string      `json:"target"`
+	Profile string      `json:"profile"`
+	Time    time.Time   `json:"time"`
+	File    string      `json:"file,omitempty"`
+	Summary *RunSummary `json:"summary"`
+}
+
+type BaselineVerdict struct {
+	Verdict         string    `json:"verdict"`
+	BaselineTime    time.Time `json:"baseline_time"`
+	ThroughputDelta float64   `json:"throughput_delta_percent"`
+	JitterDelta     float64   `json:"jitter_delta_ms"`
+	Reasons         []string  `json:"reasons,omitempty"`
+}
+
+var baselineLock sync.Mutex
+
+func baselineFilePath() string {
+	return filepath.Join(DataDirGet(), "baseline.json")
+}
+
+func BaselineTarget(config Config) string {
+	return fmt.Sprintf("%s:%d", config.ClientAddress, config.ClientPort)
+}
+
+func BaselineProfile(config Config) string {
+	return fmt.Sprintf("%s P%d t%d O%d l%d b%d%s w%d%s R%t bidir%t",
+		config.ClientProtocol, config.ClientStreams, config.ClientRunTime, config.ClientOmitSec,
+		config.ClientPayload, config.ClientBandwidth, config.ClientBandwidthUnit,
+		config.ClientWindows, config.ClientWindowsUnit, config.ClientReverseMode, config.ClientBidirectionalMode)
+}
+
+func baselineKey(config Config) string {
+	return BaselineTarget(config) + " " + BaselineProfile(config)
+}
+
+func baselineLoad() (map[string]*Baseline, error) {
+	baselines := make(map[string]*Baseline)
+	value, err := os.ReadFile(baselineFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return baselines, nil
+		}
+		return nil, err
+	}
+	err = json.Unmarshal(value, &baselines)
+	if err != nil {
+		return nil, err
+	}
+	return baselines, nil
+}
+
+func BaselinePin(config Config, summary *RunSummary) error {
+	if summary == nil || summary.Failed() {
+		return fmt.Errorf("no successful run to pin as baseline")
+	}
+
+	baselineLock.Lock()
+	defer baselineLock.Unlock()
+
+	baselines, err := baselineLoad()
+	if err != nil {
+		return err
+	}
+
+	baselines[baselineKey(config)] = &Baseline{
+		Target:  BaselineTarget(config),
+		Profile: BaselineProfile(config),
+		Time:    time.Now(),
+		File:    summary.File,
+		Summary: summary,
+	}
+
+	value, err := json.MarshalIndent(baselines, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	logs.Info("pin baseline %s", baselineKey(config))
+	return SaveToFile(baselineFilePath(), value)
+}
+
+func BaselineGet(config Config) (*Baseline, error) {
+	baselineLock.Lock()
+	defer baselineLock.Unlock()
+
+	baselines, err := baselineLoad()
+	if err != nil {
+		return nil, err
+	}
+	return baselines[baselineKey(config)], nil
+}
+
+func (b *Baseline) direction(direction string) *DirectionSummary {
+	if direction != "" {
+		if pinned := b.Summary.directionSummary(direction); pinned != nil {
+			return pinned
+		}
+	}
+	return &DirectionSummary{ReceivedBitsPerSecond: b.Summary.ReceivedBitsPerSecond}
+}
+
+func (b *Baseline) Compare(config Config, summary *RunSummary) *BaselineVerdict {
+	verdict := &BaselineVerdict{
+		Verdict:      VerdictPass,
+		BaselineTime: b.Time,
+		Reasons:      make([]string, 0),
+	}
+
+	verdict.JitterDelta = summary.JitterMs - b.Summary.JitterMs
+
+	improved := false
+
+	for i, direction := range summary.Directions() {
+		pinned := b.direction(direction.Direction)
+		delta := deltaPercent(pinned.ReceivedBitsPerSecond, direction.ReceivedBitsPerSecond)
+		if i == 0 || delta < verdict.ThroughputDelta {
+			verdict.ThroughputDelta = delta
+		}
+
+		if delta <= -config.BaselineThroughputTolerance {
+			verdict.Reasons = append(verdict.Reasons, fmt.Sprintf("%s %s is %.1f%% below baseline %s",
+				direction.label("throughput"), BitRateView(direction.ReceivedBitsPerSecond), -delta,
+				BitRateView(pinned.ReceivedBitsPerSecond)))
+		} else if delta >= config.BaselineThroughputTolerance {
+			improved = true
+		}
+	}
+
+	if summary.IsUDP() {
+		if verdict.JitterDelta >= config.BaselineJitterTolerance {
+			verdict.Reasons = append(verdict.Reasons, fmt.Sprintf("jitter %.3fms is %.3fms above baseline %.3fms",
+				summary.JitterMs, verdict.JitterDelta, b.Summary.JitterMs))
+		} else if verdict.JitterDelta <= -config.BaselineJitterTolerance {
+			improved = true
+		}
+	}
+
+	if len(verdict.Reasons) > 0 {
+		verdict.Verdict = VerdictRegressed
+	} else if improved {
+		verdict.Verdict = VerdictImproved
+	}
+
+	return verdict
+}
+
+func baselineEvaluate(config Config, summary *RunSummary) {
+	if summary == nil || summary.Failed() {
+		return
+	}
+
+	baseline, err := BaselineGet(config)
+	if err != nil {
+		logs.Warning("baseline load fail, %s", err.Error())
+		return
+	}
+	if baseline == nil || baseline.Summary == nil {
+		return
+	}
+
+	summary.Baseline = baseline.Compare(config, summary)
+
+	logs.Info("baseline verdict %s, throughput %+.1f%% jitter %+.3fms",
+		summary.Baseline.Verdict, summary.Baseline.ThroughputDelta, summary.Baseline.JitterDelta)
+	for _, reason := range summary.Baseline.Reasons {
+		logs.Warning("baseline regressed, %s", reason)
+	}
+}
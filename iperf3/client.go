@@ -3,6 +3,8 @@ package iperf3
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/astaxie/beego/logs"
@@ -25,10 +27,17 @@ var clientBidirectionalMode *walk.CheckBox
 var clientInstance *IperfServer
 var clientShutdown bool
 var clientRunning bool
+var clientRepeatView string
+var clientLastSummary *RunSummary
+var clientLastConfig Config
 
 func init() {
 	clientNumberList = make([]*walk.NumberEdit, 0)
 	clientCheckBoxMap = make(map[string]*walk.CheckBox, 0)
+
+	IntervalSubscribe(func(event IntervalEvent) {
+		ClientFlowUpdate(fmt.Sprintf("%s %.0fs %s", clientRepeatView, event.Interval.Sum.End, BitRateView(event.BitsPerSecond())))
+	})
 }
 
 func MakeClientCheckBox(name, tips string, cfg *bool, form walk.Form) CheckBox {
@@ -136,10 +145,15 @@ func ClientEnable(flag bool) {
 func ClientSwitch() {
 	clientActive.SetEnabled(false)
 	if clientRunning {
-		ClientShutdown()
-	} else {
-		go ClientActive(configCache)
+		go func() {
+			ClientShutdown()
+			clientWindow.Synchronize(func() {
+				clientActive.SetEnabled(true)
+			})
+		}()
+		return
 	}
+	go ClientActive(configCache)
 	time.Sleep(time.Millisecond * 200)
 	clientActive.SetEnabled(true)
 }
@@ -157,40 +171,162 @@ func ClientShutdown() {
 	}
 }
 
-func ClientActive(config Config) {
+func ClientActive(config Config) []*RunSummary {
+	defer ClientEnable(true)
+
+	ClientEnable(false)
+
+	summaries, records, err := clientRepeat(config)
+
+	report := clientSeriesComplete(config, summaries, records)
+	clientJUnitReport(config, records, "")
+	if report != nil {
+		ClientFlowUpdate(report.String())
+	} else if len(summaries) > 0 {
+		ClientFlowUpdate(summaries[len(summaries)-1].String())
+	} else {
+		ClientFlowUpdate("")
+	}
+
+	if err != nil {
+		ErrorBoxAction(clientWindow, err.Error())
+	}
+
+	return summaries
+}
+
+func ClientHeadless(junitPath string) int {
+	CapSignal(ClientShutdown)
+
+	config := configCache
+	if !config.ClientJsonFormat {
+		logs.Info("client headless needs json output for thresholds, enable json format")
+		config.ClientJsonFormat = true
+	}
+
+	summaries, records, err := clientRepeat(config)
+
+	report := clientSeriesComplete(config, summaries, records)
+	clientJUnitReport(config, records, junitPath)
+	if report != nil {
+		logs.Info("client headless series %s", report.String())
+	}
+
+	exitCode := headlessExitCode(summaries, len(records), err)
+
+	logs.Info("client headless exit code %d", exitCode)
+
+	InfluxClose()
+
+	return exitCode
+}
+
+func ClientCompare(items string) int {
+	list := strings.Split(items, ",")
+	if len(list) != 2 {
+		message := "compare requires two items separated by comma"
+		logs.Error("client compare %s fail, %s", items, message)
+		ErrorBoxAction(nil, message)
+		return ExitRunFailed
+	}
+	left, right := strings.TrimSpace(list[0]), strings.TrimSpace(list[1])
+
+	diff, err := Compare(left, right)
+	if err != nil {
+		logs.Error("client compare %s and %s fail, %s", left, right, err.Error())
+		ErrorBoxAction(nil, err.Error())
+		return ExitRunFailed
+	}
+	logs.Info("client compare %s and %s:\n%s", left, right, diff.String())
+
+	path, err := ExportCompare(diff, configCache.ClientLog)
+	if err != nil {
+		logs.Error("client compare export fail, %s", err.Error())
+		ErrorBoxAction(nil, err.Error())
+		return ExitRunFailed
+	}
+
+	OpenBrowserWeb(path)
+	return ExitPass
+}
+
+func clientSeriesComplete(config Config, summaries []*RunSummary, records []RunRecord) *SeriesReport {
+	if len(records) <= 1 {
+		return nil
+	}
+
+	report := NewSeriesReport(config, records)
+	if err := report.Save(config.ClientLog); err != nil {
+		logs.Warning("iperf3 series report save fail, %s", err.Error())
+	}
+	if config.ClientCsvExport && config.ClientLog != "" {
+		file := filepath.Join(config.ClientLog, fmt.Sprintf("iperf3_series_%s.csv", GetTimestamp()))
+		if err := ExportCSV(seriesResults(summaries), file); err != nil {
+			logs.Warning("iperf3 series csv export fail, %s", err.Error())
+		}
+	}
+	if config.ClientHtmlReport && config.ClientLog != "" {
+		file := filepath.Join(config.ClientLog, fmt.Sprintf("iperf3_series_%s.html", GetTimestamp()))
+		if err := ExportHTMLReport(seriesSummaries(summaries), file); err != nil {
+			logs.Warning("iperf3 series html report fail, %s", err.Error())
+		}
+	}
+	return report
+}
+
+func clientRepeat(config Config) ([]*RunSummary, []RunRecord, error) {
 	var err error
+	var runErr error
 
-	defer ClientFlowUpdate("")
-	defer ClientEnable(true)
+	summaries := make([]*RunSummary, 0)
+	records := make([]RunRecord, 0)
 
 	logs.Info("client active startup")
 
 	repeatCount := config.ClientRepeatCount
 	repeatInterval := config.ClientRepeatInterval
 
-	ClientEnable(false)
+	engine := EngineGet(config)
 
 	clientRunning = true
 	for i := 0; i < repeatCount; i++ {
+		clientRepeatView = fmt.Sprintf("%d/%d", i+1, repeatCount)
 		ClientFlowUpdate(fmt.Sprintf("Repeat Times: %d/%d", i+1, repeatCount))
 
 		if clientShutdown {
 			break
 		}
 
-		clientInstance, err = ClientStartup(i)
+		clientInstance, err = ClientStartup(engine, config, i)
 		if err != nil {
-			ErrorBoxAction(clientWindow, err.Error())
+			runErr = err
 			break
 		}
 
-		for {
-			if !clientInstance.running {
+		summary, iperfErr := clientInstance.Wait()
+		clientInstance = nil
+
+		records = append(records, RunRecord{Index: i + 1, Summary: summary, Err: iperfErr})
+
+		if summary != nil {
+			if !summary.Failed() {
+				clientLastSummary = summary
+				clientLastConfig = config
+			}
+			summaries = append(summaries, summary)
+			ClientFlowUpdate(fmt.Sprintf("%d/%d %s", i+1, repeatCount, summary.String()))
+		}
+
+		if iperfErr != nil {
+			ClientFlowUpdate(fmt.Sprintf("%d/%d Error: %s", i+1, repeatCount, iperfErr.Kind))
+			if !iperfErr.Retryable() {
+				if iperfErr.Kind != ErrorInterrupted {
+					runErr = fmt.Errorf("%s", iperfErr.Message)
+				}
 				break
 			}
-			time.Sleep(time.Millisecond * 200)
+			logs.Info("iperf client run %d failed with %s, retry on next repeat", i+1, iperfErr.Kind)
 		}
-		clientInstance = nil
 
 		if i+1 == repeatCount || clientShutdown {
 			break
@@ -204,12 +340,19 @@ func ClientActive(config Config) {
 	logs.Info("client active stop")
 
 	time.Sleep(time.Millisecond * 200)
+
+	return summaries, records, runErr
 }
 
 func ClientFlowUpdate(value string) {
-	if clientFlowBar != nil {
-		clientFlowBar.SetText(value)
+	if clientWindow == nil || clientFlowBar == nil {
+		return
 	}
+	clientWindow.Synchronize(func() {
+		if clientFlowBar != nil {
+			clientFlowBar.SetText(value)
+		}
+	})
 }
 
 func ClientStatusUpdate(value string) {
@@ -252,6 +395,29 @@ func ClientWindows() error {
 					OpenBrowserWeb(RunlogDirGet())
 				},
 			},
+			Action{
+				Text: "Export CSV",
+				OnTriggered: func() {
+					file := filepath.Join(configCache.ClientLog, fmt.Sprintf("iperf3_export_%s.csv", GetTimestamp()))
+					err := ExportFolderCSV(configCache.ClientLog, file)
+					if err != nil {
+						ErrorBoxAction(clientWindow, err.Error())
+						return
+					}
+					InfoBoxAction(clientWindow, "Export to "+file)
+				},
+			},
+			Action{
+				Text: "Pin Baseline",
+				OnTriggered: func() {
+					err := BaselinePin(clientLastConfig, clientLastSummary)
+					if err != nil {
+						ErrorBoxAction(clientWindow, err.Error())
+						return
+					}
+					InfoBoxAction(clientWindow, "Pin baseline "+clientLastSummary.String())
+				},
+			},
 			Action{
 				Text: "Sponsor",
 				OnTriggered: func() {
@@ -269,7 +435,7 @@ func ClientWindows() error {
 			{
 				AssignTo: &clientFlowBar,
 				Icon:     ICON_Flow,
-				Width:    160,
+				Width:    300,
 			},
 		},
 		Children: []Widget{
@@ -530,6 +696,10 @@ func ClientWindows() error {
 										}
 									}
 								}),
+
+							MakeClientCheckBox("CSV Export", "Export interval and summary data as CSV next to the JSON report", &configCache.ClientCsvExport, clientWindow),
+							MakeClientCheckBox("HTML Report", "Write an offline HTML report with throughput charts next to the JSON report", &configCache.ClientHtmlReport, clientWindow),
+							MakeClientCheckBox("JUnit Report", "Write a JUnit XML report for CI pipelines after each test", &configCache.ClientJUnitReport, clientWindow),
 						},
 					},
 
@@ -3,5 +3,6 @@ package iperf3
 func CloseWindows() {
 	ClientClose()
 	ServerClose()
+	InfluxClose()
 	NotifyExit()
 }
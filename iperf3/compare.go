@@ -0,0 +1,207 @@
+package iperf3
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/astaxie/beego/logs"
+)
+
+type DiffRow struct {
+	Name         string  `json:"name"`
+	Left         string  `json:"left"`
+	Right        string  `json:"right"`
+	Delta        float64 `json:"delta"`
+	DeltaPercent float64 `json:"delta_percent"`
+	Numeric      bool    `json:"numeric"`
+}
+
+type ResultDiff struct {
+	Left    string    `json:"left"`
+	Right   string    `json:"right"`
+	Config  []DiffRow `json:"config"`
+	Metrics []DiffRow `json:"metrics"`
+}
+
+func (r DiffRow) Changed() bool {
+	return r.Left != r.Right
+}
+
+func textRow(name string, left, right interface{}) DiffRow {
+	return DiffRow{Name: name, Left: fmt.Sprint(left), Right: fmt.Sprint(right)}
+}
+
+func numberRow(name string, left, right float64, view func(float64) string) DiffRow {
+	return DiffRow{
+		Name:         name,
+		Left:         view(left),
+		Right:        view(right),
+		Delta:        right - left,
+		DeltaPercent: deltaPercent(left, right),
+		Numeric:      true,
+	}
+}
+
+func structRows(prefix string, left, right interface{}) []DiffRow {
+	rows := make([]DiffRow, 0)
+	lv := reflect.ValueOf(left)
+	rv := reflect.ValueOf(right)
+	for i := 0; i < lv.NumField(); i++ {
+		field := lv.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		rows = append(rows, textRow(prefix+field.Name, lv.Field(i).Interface(), rv.Field(i).Interface()))
+	}
+	return rows
+}
+
+func plainView(format string) func(float64) string {
+	return func(value float64) string {
+		return fmt.Sprintf(format, value)
+	}
+}
+
+func metricRows(left, right *RunSummary) []DiffRow {
+	rows := []DiffRow{
+		numberRow("duration", left.Duration, right.Duration, plainView("%.1fs")),
+		numberRow("sent rate", left.SentBitsPerSecond, right.SentBitsPerSecond, BitRateView),
+		numberRow("received rate", left.ReceivedBitsPerSecond, right.ReceivedBitsPerSecond, BitRateView),
+		numberRow("retransmits", float64(left.Retransmits), float64(right.Retransmits), plainView("%.0f")),
+		numberRow("mean rtt", float64(left.MeanRtt), float64(right.MeanRtt), plainView("%.0fus")),
+		numberRow("jitter", left.JitterMs, right.JitterMs, plainView("%.3fms")),
+		numberRow("loss", left.LostPercent, right.LostPercent, plainView("%.2f%%")),
+		numberRow("host cpu", left.HostCpu, right.HostCpu, plainView("%.1f%%")),
+		numberRow("remote cpu", left.RemoteCpu, right.RemoteCpu, plainView("%.1f%%")),
+	}
+
+	for _, direction := range []string{DirectionUpload, DirectionDownload} {
+		l, r := left.directionSummary(direction), right.directionSummary(direction)
+		if l == nil || r == nil {
+			continue
+		}
+		rows = append(rows, numberRow(direction+" rate", l.ReceivedBitsPerSecond, r.ReceivedBitsPerSecond, BitRateView))
+	}
+
+	return rows
+}
+
+func (s *RunSummary) directionSummary(direction string) *DirectionSummary {
+	if direction == DirectionUpload {
+		return s.Upload
+	}
+	return s.Download
+}
+
+func CompareSummaries(leftName, rightName string, left, right *RunSummary) *ResultDiff {
+	diff := &ResultDiff{
+		Left:    leftName,
+		Right:   rightName,
+		Config:  make([]DiffRow, 0),
+		Metrics: metricRows(left, right),
+	}
+
+	if left.Result() != nil && right.Result() != nil {
+		ls, rs := left.Result().Start, right.Result().Start
+		diff.Config = append(diff.Config, textRow("Target", left.Target, right.Target))
+		diff.Config = append(diff.Config, textRow("Version", ls.Version, rs.Version))
+		diff.Config = append(diff.Config, textRow("SystemInfo", ls.SystemInfo, rs.SystemInfo))
+		diff.Config = append(diff.Config, textRow("TcpMssDefault", ls.TcpMssDefault, rs.TcpMssDefault))
+		diff.Config = append(diff.Config, structRows("TestStart.", ls.TestStart, rs.TestStart)...)
+	}
+
+	return diff
+}
+
+func CompareFiles(leftFile, rightFile string) (*ResultDiff, error) {
+	left, err := LoadResult(leftFile)
+	if err != nil {
+		return nil, err
+	}
+	right, err := LoadResult(rightFile)
+	if err != nil {
+		return nil, err
+	}
+	return CompareSummaries(leftFile, rightFile, NewRunSummary(left), NewRunSummary(right)), nil
+}
+
+func historySummary(entry *HistoryEntry) (*RunSummary, error) {
+	if entry.File != "" {
+		result, err := LoadResult(entry.File)
+		if err == nil {
+			return NewRunSummary(result), nil
+		}
+	}
+	if entry.Summary == nil {
+		return nil, fmt.Errorf("history entry %s has no result", entry.ID)
+	}
+	return entry.Summary, nil
+}
+
+func CompareHistory(leftID, rightID string) (*ResultDiff, error) {
+	leftEntry, err := HistoryGet(leftID)
+	if err != nil {
+		return nil, err
+	}
+	rightEntry, err := HistoryGet(rightID)
+	if err != nil {
+		return nil, err
+	}
+
+	left, err := historySummary(leftEntry)
+	if err != nil {
+		return nil, err
+	}
+	right, err := historySummary(rightEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := CompareSummaries(leftID, rightID, left, right)
+	diff.Config = append(diff.Config, structRows("Config.", leftEntry.Config.Redacted(), rightEntry.Config.Redacted())...)
+	return diff, nil
+}
+
+func Compare(left, right string) (*ResultDiff, error) {
+	if strings.HasSuffix(strings.ToLower(left), ".json") && strings.HasSuffix(strings.ToLower(right), ".json") {
+		return CompareFiles(left, right)
+	}
+	return CompareHistory(left, right)
+}
+
+func (d *ResultDiff) String() string {
+	builder := strings.Builder{}
+	fmt.Fprintf(&builder, "left:  %s\nright: %s\n\n", d.Left, d.Right)
+
+	fmt.Fprintf(&builder, "%-2s %-34s %-24s %-24s\n", "", "configuration", "left", "right")
+	for _, row := range d.Config {
+		mark := ""
+		if row.Changed() {
+			mark = "*"
+		}
+		fmt.Fprintf(&builder, "%-2s %-34s %-24s %-24s\n", mark, row.Name, row.Left, row.Right)
+	}
+
+	fmt.Fprintf(&builder, "\n%-2s %-34s %-24s %-24s %14s %10s\n", "", "metric", "left", "right", "delta", "delta%")
+	for _, row := range d.Metrics {
+		mark := ""
+		if row.Changed() {
+			mark = "*"
+		}
+		fmt.Fprintf(&builder, "%-2s %-34s %-24s %-24s %+14.3f %+9.2f%%\n",
+			mark, row.Name, row.Left, row.Right, row.Delta, row.DeltaPercent)
+	}
+
+	return builder.String()
+}
+
+func ExportCompare(diff *ResultDiff, dir string) (string, error) {
+	path := filepath.Join(dir, fmt.Sprintf("iperf3_compare_%s.txt", GetTimestamp()))
+	if err := SaveToFile(path, []byte(diff.String())); err != nil {
+		return "", err
+	}
+	logs.Info("export compare %s", path)
+	return path, nil
+}
@@ -44,7 +44,30 @@ type Config struct {
 	ClientTypeService       int
 	ClientRepeatCount       int
 	ClientRepeatInterval    int
+	ClientWatchdogAllowance int // seconds
 	ClientLog               string
+	ClientCsvExport         bool
+	ClientHtmlReport        bool
+	ClientJUnitReport       bool
+
+	BaselineThroughputTolerance float64 // percent
+	BaselineJitterTolerance     float64 // ms
+
+	ThresholdMinThroughput  float64 // Mbits/sec
+	ThresholdMaxLoss        float64 // percent
+	ThresholdMaxJitter      float64 // ms
+	ThresholdMaxRetransmits int
+	ThresholdMaxCpu         float64 // percent
+
+	AnomalyDipFraction     float64 // fraction of median throughput
+	AnomalyRetransmitBurst float64 // factor of median retransmits
+
+	Engine string
+
+	MetricsListen string
+	InfluxFile    string
+	InfluxURL     string
+	InfluxToken   string
 }
 
 var configCache = Config{
@@ -82,12 +105,44 @@ var configCache = Config{
 	ClientTypeService:       0,
 	ClientRepeatCount:       1,
 	ClientRepeatInterval:    0,
+	ClientWatchdogAllowance: 30,
 	ClientLog:               "",
+	ClientCsvExport:         false,
+	ClientHtmlReport:        false,
+	ClientJUnitReport:       false,
+
+	BaselineThroughputTolerance: 10,
+	BaselineJitterTolerance:     5,
+
+	ThresholdMinThroughput:  0,
+	ThresholdMaxLoss:        0,
+	ThresholdMaxJitter:      0,
+	ThresholdMaxRetransmits: 0,
+	ThresholdMaxCpu:         0,
+
+	AnomalyDipFraction:     0.5,
+	AnomalyRetransmitBurst: 5,
+
+	Engine: EngineBundled,
+
+	MetricsListen: "",
+	InfluxFile:    "",
+	InfluxURL:     "",
+	InfluxToken:   "",
 }
 
 var configFilePath string
 var configLock sync.Mutex
 
+const redactedSecret = "******"
+
+func (c Config) Redacted() Config {
+	if c.InfluxToken != "" {
+		c.InfluxToken = redactedSecret
+	}
+	return c
+}
+
 func configSyncToFile() error {
 	configLock.Lock()
 	defer configLock.Unlock()
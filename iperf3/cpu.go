@@ -2,6 +2,7 @@ package iperf3
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/astaxie/beego/logs"
@@ -9,18 +10,66 @@ import (
 	"github.com/shirou/gopsutil/mem"
 )
 
-func cpuInfo() string {
+var cpuLock sync.Mutex
+var cpuLastPercent, memLastPercent float64
+var corePeakPercent []float64
+
+func cpuUsage() (float64, float64, error) {
 	cpuPercent, err := cpu.Percent(0, false)
 	if err != nil {
 		logs.Warning("Get CPU Usage failed, %s", err.Error())
-		return ""
+		return 0, 0, err
 	}
 	memInfo, err := mem.VirtualMemory()
 	if err != nil {
 		logs.Warning("Get Memory Usage failed, %s", err.Error())
+		return 0, 0, err
+	}
+
+	corePercent, err := cpu.Percent(0, true)
+	if err != nil {
+		logs.Warning("Get CPU Core Usage failed, %s", err.Error())
+	}
+
+	cpuLock.Lock()
+	cpuLastPercent = cpuPercent[0]
+	memLastPercent = memInfo.UsedPercent
+	for i, percent := range corePercent {
+		if i >= len(corePeakPercent) {
+			corePeakPercent = append(corePeakPercent, percent)
+		} else if percent > corePeakPercent[i] {
+			corePeakPercent[i] = percent
+		}
+	}
+	cpuLock.Unlock()
+
+	return cpuPercent[0], memInfo.UsedPercent, nil
+}
+
+func cpuUsageLast() (float64, float64) {
+	cpuLock.Lock()
+	defer cpuLock.Unlock()
+	return cpuLastPercent, memLastPercent
+}
+
+func cpuPeakReset() {
+	cpuLock.Lock()
+	corePeakPercent = nil
+	cpuLock.Unlock()
+}
+
+func cpuPeakCores() []float64 {
+	cpuLock.Lock()
+	defer cpuLock.Unlock()
+	return append([]float64{}, corePeakPercent...)
+}
+
+func cpuInfo() string {
+	cpuPercent, memPercent, err := cpuUsage()
+	if err != nil {
 		return ""
 	}
-	return fmt.Sprintf("CPU: %.2f%% MEM: %.2f%%", cpuPercent[0], memInfo.UsedPercent)
+	return fmt.Sprintf("CPU: %.2f%% MEM: %.2f%%", cpuPercent, memPercent)
 }
 
 func init() {
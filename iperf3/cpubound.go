@@ -0,0 +1,132 @@
+package iperf3
+
+import (
+	"fmt"
+
+	"github.com/astaxie/beego/logs"
+)
+
+const (
+	CpuBoundPercent      = 90.0
+	CpuCoreSaturated     = 95.0
+	cpuBoundPayloadLimit = 128 * 1024
+)
+
+const (
+	CpuSideHost   = "host"
+	CpuSideRemote = "remote"
+)
+
+type CpuFinding struct {
+	Side    string  `json:"side"`
+	Role    string  `json:"role"`
+	Percent float64 `json:"percent"`
+	Core    int     `json:"core"`
+	Message string  `json:"message"`
+}
+
+type CpuBoundReport struct {
+	Findings []CpuFinding `json:"findings"`
+	Remedies []string     `json:"remedies"`
+}
+
+func cpuRole(result *Result, side string) string {
+	if result.IsBidir() {
+		return "sender/receiver"
+	}
+	sender := result.IsReverse()
+	if side == CpuSideHost {
+		sender = !sender
+	}
+	if sender {
+		return "sender"
+	}
+	return "receiver"
+}
+
+func cpuRemedies(config Config, summary *RunSummary) []string {
+	remedies := make([]string, 0)
+	if config.ClientStreams <= 1 {
+		remedies = append(remedies, "increase parallel streams so the load spreads across cores")
+	}
+	if !summary.IsUDP() {
+		if config.ClientPayload < cpuBoundPayloadLimit {
+			remedies = append(remedies, fmt.Sprintf("raise the payload length from %s to reduce per-call overhead", ByteView(int64(config.ClientPayload))))
+		}
+		if !config.ClientZeroCopy {
+			remedies = append(remedies, "enable zero-copy to save a copy per send")
+		}
+	}
+	remedies = append(remedies, "close other busy programs or test from a faster machine")
+	return remedies
+}
+
+func NewCpuBoundReport(config Config, summary *RunSummary, cores []float64) *CpuBoundReport {
+	result := summary.Result()
+	if result == nil {
+		return nil
+	}
+
+	findings := make([]CpuFinding, 0)
+	sides := []struct {
+		side    string
+		percent float64
+	}{
+		{CpuSideHost, summary.HostCpu},
+		{CpuSideRemote, summary.RemoteCpu},
+	}
+	for _, item := range sides {
+		if item.percent < CpuBoundPercent {
+			continue
+		}
+		role := cpuRole(result, item.side)
+		findings = append(findings, CpuFinding{
+			Side:    item.side,
+			Role:    role,
+			Percent: item.percent,
+			Core:    -1,
+			Message: fmt.Sprintf("%s %s cpu at %.1f%%", item.side, role, item.percent),
+		})
+	}
+
+	for core, percent := range cores {
+		if percent < CpuCoreSaturated {
+			continue
+		}
+		role := cpuRole(result, CpuSideHost)
+		findings = append(findings, CpuFinding{
+			Side:    CpuSideHost,
+			Role:    role,
+			Percent: percent,
+			Core:    core,
+			Message: fmt.Sprintf("host %s core %d saturated at %.1f%%", role, core, percent),
+		})
+	}
+
+	if len(findings) == 0 {
+		return nil
+	}
+
+	return &CpuBoundReport{
+		Findings: findings,
+		Remedies: cpuRemedies(config, summary),
+	}
+}
+
+func cpuBoundEvaluate(config Config, summary *RunSummary) {
+	cores := cpuPeakCores()
+	if summary == nil || summary.Failed() {
+		return
+	}
+
+	summary.CpuBound = NewCpuBoundReport(config, summary, cores)
+	if summary.CpuBound == nil {
+		return
+	}
+	for _, finding := range summary.CpuBound.Findings {
+		logs.Warning("iperf3 cpu bound, %s", finding.Message)
+	}
+	for _, remedy := range summary.CpuBound.Remedies {
+		logs.Warning("iperf3 cpu bound remedy, %s", remedy)
+	}
+}
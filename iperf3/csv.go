@@ -0,0 +1,158 @@
+package iperf3
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+)
+
+var csvHeader = []string{
+	"type", "run", "timestamp", "target", "protocol", "stream", "direction",
+	"start", "end", "bytes", "bits_per_second",
+	"retransmits", "jitter_ms", "lost_packets", "packets", "lost_percent", "omitted",
+}
+
+func csvFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func csvInt(value int64) string {
+	return strconv.FormatInt(value, 10)
+}
+
+func resultTimestamp(result *Result, offset float64) string {
+	if result.Start.Timestamp.TimeSecs == 0 {
+		return ""
+	}
+	base := time.Unix(result.Start.Timestamp.TimeSecs, 0)
+	return base.Add(time.Duration(offset * float64(time.Second))).Format(time.RFC3339)
+}
+
+func csvStreamRow(result *Result, run int, stream *Stream) []string {
+	return []string{
+		"interval", strconv.Itoa(run), resultTimestamp(result, stream.Start),
+		"", "", csvInt(stream.Socket), stream.Direction,
+		csvFloat(stream.Start), csvFloat(stream.End), csvInt(stream.Bytes), csvFloat(stream.BitPerSecond),
+		csvInt(stream.Retransmits), csvFloat(stream.JitterMs), csvInt(stream.LostPackets),
+		csvInt(stream.Packets), csvFloat(stream.LostPercent), strconv.FormatBool(stream.Omitted),
+	}
+}
+
+func csvSumRow(result *Result, run int, direction string, sum *Sum) []string {
+	return []string{
+		"sum", strconv.Itoa(run), resultTimestamp(result, sum.Start),
+		"", "", "SUM", direction,
+		csvFloat(sum.Start), csvFloat(sum.End), csvInt(sum.Bytes), csvFloat(sum.BitPerSecond),
+		csvInt(sum.Retransmits), csvFloat(sum.JitterMs), csvInt(sum.LostPackets),
+		csvInt(sum.Packets), csvFloat(sum.LostPercent), strconv.FormatBool(sum.Omitted),
+	}
+}
+
+func csvSummaryRow(result *Result, run int, summary *RunSummary) []string {
+	return []string{
+		"summary", strconv.Itoa(run), resultTimestamp(result, 0),
+		summary.Target, summary.Protocol, "ALL", "",
+		"0", csvFloat(summary.Duration), csvInt(summary.ReceivedBytes), csvFloat(summary.ReceivedBitsPerSecond),
+		csvInt(summary.Retransmits), csvFloat(summary.JitterMs), csvInt(summary.LostPackets),
+		csvInt(summary.Packets), csvFloat(summary.LostPercent), "false",
+	}
+}
+
+func csvResultRows(result *Result, run int) [][]string {
+	rows := make([][]string, 0)
+	for i := range result.Intervals {
+		interval := &result.Intervals[i]
+		for j := range interval.Streams {
+			rows = append(rows, csvStreamRow(result, run, &interval.Streams[j]))
+		}
+		for _, direction := range []string{DirectionUpload, DirectionDownload} {
+			sum, ok := result.IntervalSum(interval, direction)
+			if ok {
+				rows = append(rows, csvSumRow(result, run, direction, &sum))
+			}
+		}
+	}
+	rows = append(rows, csvSummaryRow(result, run, NewRunSummary(result)))
+	return rows
+}
+
+func WriteCSV(w io.Writer, results []*Result) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for i, result := range results {
+		if err := writer.WriteAll(csvResultRows(result, i+1)); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func ExportCSV(results []*Result, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	err = WriteCSV(file, results)
+	if err != nil {
+		return err
+	}
+
+	logs.Info("export csv file %s with %d runs", path, len(results))
+	return nil
+}
+
+func ResultFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "iperf3_*.json"))
+	if err != nil {
+		return nil, err
+	}
+	output := make([]string, 0)
+	for _, file := range files {
+		if strings.HasPrefix(filepath.Base(file), "iperf3_series_") {
+			continue
+		}
+		output = append(output, file)
+	}
+	sort.Strings(output)
+	return output, nil
+}
+
+func ExportFolderCSV(dir string, path string) error {
+	files, err := ResultFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	results := make([]*Result, 0)
+	for _, file := range files {
+		result, err := LoadResult(file)
+		if err != nil {
+			logs.Warning("load result %s fail, %s", file, err.Error())
+			continue
+		}
+		results = append(results, result)
+	}
+
+	if len(results) == 0 {
+		return fmt.Errorf("no iperf3 result found in %s", dir)
+	}
+
+	return ExportCSV(results, path)
+}
+
+func csvFilePath(jsonFile string) string {
+	return strings.TrimSuffix(jsonFile, filepath.Ext(jsonFile)) + ".csv"
+}
@@ -0,0 +1,98 @@
+package iperf3
+
+const (
+	DirectionUpload   = "upload"
+	DirectionDownload = "download"
+)
+
+func (r *Result) IsClientSide() bool {
+	return r.Start.ConnectingTo.Host != ""
+}
+
+func (r *Result) IsBidir() bool {
+	return r.Start.TestStart.Bidir != 0
+}
+
+func (r *Result) IsReverse() bool {
+	return r.Start.TestStart.Reverse != 0
+}
+
+// Directions are always named from the client point of view, the local
+// sending flow of a server side result is therefore the download.
+func (r *Result) localDirections() (string, string) {
+	if r.IsClientSide() {
+		return DirectionUpload, DirectionDownload
+	}
+	return DirectionDownload, DirectionUpload
+}
+
+func (r *Result) flowDirection() string {
+	if r.IsReverse() {
+		return DirectionDownload
+	}
+	return DirectionUpload
+}
+
+func (r *Result) streamDirection(sender bool) string {
+	if !r.IsBidir() {
+		return r.flowDirection()
+	}
+	send, recv := r.localDirections()
+	if sender {
+		return send
+	}
+	return recv
+}
+
+func (r *Result) LabelDirections() {
+	if r.ServerOutputJson != nil {
+		r.ServerOutputJson.LabelDirections()
+	}
+
+	for i := range r.Intervals {
+		for j := range r.Intervals[i].Streams {
+			stream := &r.Intervals[i].Streams[j]
+			stream.Direction = r.streamDirection(stream.Sender)
+		}
+	}
+
+	for i := range r.End.Streams {
+		stream := &r.End.Streams[i]
+		direction := r.streamDirection(stream.Sender.Sender)
+		if r.IsUDP() {
+			direction = r.streamDirection(stream.Udp.Sender)
+		}
+		stream.Sender.Direction = direction
+		stream.Receiver.Direction = direction
+		stream.Udp.Direction = direction
+	}
+}
+
+// SumsByDirection returns the sender and receiver side totals of the
+// given direction, ok is false when the run has no such flow.
+func (r *Result) SumsByDirection(direction string) (Sum, Sum, bool) {
+	if !r.IsBidir() {
+		if direction != r.flowDirection() {
+			return Sum{}, Sum{}, false
+		}
+		return r.End.SumSender, r.End.SumReceiver, true
+	}
+
+	send, _ := r.localDirections()
+	if direction == send {
+		return r.End.SumSender, r.End.SumReceiver, true
+	}
+	return r.End.SumSenderBidirReverse, r.End.SumReceiverBidirReverse, true
+}
+
+func (r *Result) IntervalSum(interval *Interval, direction string) (Sum, bool) {
+	if !r.IsBidir() {
+		return interval.Sum, direction == r.flowDirection()
+	}
+
+	send, _ := r.localDirections()
+	if direction == send {
+		return interval.Sum, true
+	}
+	return interval.SumBidirReverse, true
+}
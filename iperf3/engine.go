@@ -0,0 +1,546 @@
+package iperf3
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+)
+
+const (
+	EngineBundled = "bundled"
+	EngineSystem  = "system"
+	EngineFake    = "fake"
+)
+
+const (
+	RoleClient = "client"
+	RoleServer = "server"
+)
+
+const (
+	runEventBuffer  = 256
+	runResultBuffer = 16
+)
+
+type TestSpec struct {
+	Role         string
+	Listen       string
+	Address      string
+	Port         int
+	Interval     int
+	Duration     int
+	Omit         int
+	Streams      int
+	Protocol     string
+	Bandwidth    string
+	Window       string
+	Payload      int
+	NoDelay      bool
+	ZeroCopy     bool
+	Reverse      bool
+	Bidir        bool
+	Json         bool
+	Dscp         int
+	Tos          int
+	DontFragment bool
+	SetMss       bool
+	IPv4         bool
+	IPv6         bool
+	OutputDir    string
+}
+
+type Engine interface {
+	Name() string
+	Run(spec TestSpec) (RunHandle, error)
+}
+
+type RunHandle interface {
+	Events() <-chan IntervalEvent
+	Results() <-chan *RunSummary
+	Done() <-chan struct{}
+	Wait() (*RunSummary, *IperfError)
+	ExitCode() int
+	Stop()
+	Cancel()
+}
+
+var engineOverride Engine
+
+func EngineSet(engine Engine) {
+	engineOverride = engine
+}
+
+func EngineGet(config Config) Engine {
+	if engineOverride != nil {
+		return engineOverride
+	}
+	if config.Engine == EngineSystem {
+		engine, err := SystemEngine()
+		if err == nil {
+			return engine
+		}
+		logs.Warning("iperf3 system engine unavailable, %s, use bundled", err.Error())
+	}
+	return BundledEngine()
+}
+
+func NewServerSpec(config Config, index int) TestSpec {
+	return TestSpec{
+		Role:      RoleServer,
+		Listen:    config.ServerListen,
+		Port:      config.ServerPort + index,
+		Interval:  config.ServerInterval,
+		Json:      config.ServerJsonFormat,
+		OutputDir: config.ServerLog,
+	}
+}
+
+func NewClientSpec(config Config) TestSpec {
+	spec := TestSpec{
+		Role:         RoleClient,
+		Listen:       config.ClientListen,
+		Address:      config.ClientAddress,
+		Port:         config.ClientPort,
+		Interval:     1,
+		Duration:     config.ClientRunTime,
+		Omit:         config.ClientOmitSec,
+		Streams:      config.ClientStreams,
+		Protocol:     config.ClientProtocol,
+		Payload:      config.ClientPayload,
+		NoDelay:      config.ClientNoDelay,
+		ZeroCopy:     config.ClientZeroCopy,
+		Reverse:      config.ClientReverseMode,
+		Bidir:        config.ClientBidirectionalMode,
+		Json:         config.ClientJsonFormat,
+		Dscp:         config.ClientDscpValue,
+		Tos:          config.ClientTypeService,
+		DontFragment: config.ClientDontFragment,
+		SetMss:       config.ClientMaxmumSegment,
+		IPv4:         config.ClientOnlyIPv4,
+		IPv6:         config.ClientOnlyIPv6,
+		OutputDir:    config.ClientLog,
+	}
+	if config.ClientBandwidth > 0 {
+		spec.Bandwidth = fmt.Sprintf("%d%c", config.ClientBandwidth, config.ClientBandwidthUnit[0])
+	}
+	if config.ClientWindows > 0 {
+		spec.Window = fmt.Sprintf("%d%c", config.ClientWindows, config.ClientWindowsUnit[0])
+	}
+	return spec
+}
+
+func (s TestSpec) IsClient() bool {
+	return s.Role == RoleClient
+}
+
+func (s TestSpec) Target() string {
+	return fmt.Sprintf("%s:%d", s.Address, s.Port)
+}
+
+func (s TestSpec) serverArgs() []string {
+	builder := strings.Builder{}
+	builder.WriteString(" -s")
+
+	fmt.Fprintf(&builder, " -B %s", s.Listen)
+	fmt.Fprintf(&builder, " --port %d", s.Port)
+
+	if s.Interval > 0 {
+		fmt.Fprintf(&builder, " --interval %d", s.Interval)
+	}
+
+	if s.Json {
+		fmt.Fprintf(&builder, " --json %d", s.Interval)
+	}
+
+	fmt.Fprintf(&builder, " --forceflush")
+
+	return strings.Fields(builder.String())
+}
+
+func (s TestSpec) clientArgs() []string {
+	builder := strings.Builder{}
+
+	fmt.Fprintf(&builder, " -B %s", s.Listen)
+	fmt.Fprintf(&builder, " -c %s", s.Address)
+	fmt.Fprintf(&builder, " -p %d", s.Port)
+	fmt.Fprintf(&builder, " -t %d", s.Duration)
+	fmt.Fprintf(&builder, " -P %d", s.Streams)
+	fmt.Fprintf(&builder, " --interval %d", s.Interval)
+
+	if s.Omit > 0 {
+		fmt.Fprintf(&builder, " -O %d", s.Omit)
+	}
+
+	if s.Bandwidth != "" {
+		fmt.Fprintf(&builder, " -b %s", s.Bandwidth)
+	}
+
+	if s.Window != "" {
+		fmt.Fprintf(&builder, " -w %s", s.Window)
+	}
+
+	if s.Protocol == "udp" {
+		builder.WriteString(" -u")
+	}
+
+	if s.NoDelay {
+		builder.WriteString(" -N")
+	}
+
+	if s.ZeroCopy {
+		builder.WriteString(" -Z")
+	}
+
+	if s.Reverse {
+		builder.WriteString(" -R")
+	}
+
+	if s.Bidir {
+		builder.WriteString(" --bidir")
+	}
+
+	if s.Payload > 0 {
+		fmt.Fprintf(&builder, " -l %d", s.Payload)
+	}
+
+	if s.Json {
+		builder.WriteString(" --json-stream")
+	}
+
+	if s.Dscp > 0 {
+		fmt.Fprintf(&builder, " --dscp %d", s.Dscp)
+	}
+
+	if s.Tos > 0 {
+		fmt.Fprintf(&builder, " --tos %d", s.Tos)
+	}
+
+	if s.DontFragment {
+		builder.WriteString(" --dont-fragment")
+	}
+
+	if s.SetMss {
+		builder.WriteString(" --set-mss")
+	}
+
+	if s.IPv4 {
+		builder.WriteString(" --version4")
+	}
+
+	if s.IPv6 {
+		builder.WriteString(" --version6")
+	}
+
+	builder.WriteString(" --get-server-output")
+	builder.WriteString(" --forceflush")
+
+	return strings.Fields(builder.String())
+}
+
+func (s TestSpec) Args() []string {
+	if s.IsClient() {
+		return s.clientArgs()
+	}
+	return s.serverArgs()
+}
+
+func (s TestSpec) start() Start {
+	start := Start{
+		ConnectingTo: Connecting{
+			Host: s.Address,
+			Port: int64(s.Port),
+		},
+	}
+	start.TestStart.Protocol = s.Protocol
+	start.TestStart.NumStreams = int64(s.Streams)
+	start.TestStart.Duration = int64(s.Duration)
+	if s.Reverse {
+		start.TestStart.Reverse = 1
+	}
+	if s.Bidir {
+		start.TestStart.Bidir = 1
+	}
+	return start
+}
+
+type runHandle struct {
+	spec     TestSpec
+	parser   *IntervalParser
+	events   chan IntervalEvent
+	results  chan *RunSummary
+	object   strings.Builder
+	last     *RunSummary
+	done     chan struct{}
+	cancel   func()
+	stop     func()
+	stopped  atomic.Bool
+	summary  *RunSummary
+	err      *IperfError
+	exitCode int
+}
+
+func newRunHandle(spec TestSpec) *runHandle {
+	handle := &runHandle{
+		spec:    spec,
+		events:  make(chan IntervalEvent, runEventBuffer),
+		results: make(chan *RunSummary, runResultBuffer),
+		done:    make(chan struct{}),
+	}
+	if spec.IsClient() {
+		handle.parser = NewIntervalParser(spec.start(), handle.publish)
+	}
+	return handle
+}
+
+func (h *runHandle) publish(event IntervalEvent) {
+	select {
+	case h.events <- event:
+	default:
+		logs.Warning("iperf3 interval %d dropped, event buffer full", event.Index)
+	}
+}
+
+func (h *runHandle) parseLine(line string) {
+	if h.parser != nil {
+		h.parser.ParseLine(line)
+		return
+	}
+	if h.spec.Json {
+		h.parseObject(line)
+	}
+}
+
+func (h *runHandle) parseObject(line string) {
+	if h.object.Len() == 0 && line != "{" {
+		return
+	}
+	h.object.WriteString(line)
+	h.object.WriteByte('\n')
+	if line != "}" {
+		return
+	}
+
+	text := []byte(h.object.String())
+	h.object.Reset()
+
+	summary, err := ParseResult(text, h.spec.OutputDir)
+	if err != nil {
+		logs.Warning("iperf server read result failed, %s", err.Error())
+		return
+	}
+	h.last = summary
+
+	select {
+	case h.results <- summary:
+	default:
+		logs.Warning("iperf3 server result %s dropped, result buffer full", summary.Target)
+	}
+}
+
+func (h *runHandle) finish(stdout []byte, stderr string, exitCode int) {
+	if h.parser != nil {
+		h.parser.Close()
+	}
+
+	var summary *RunSummary
+	if h.spec.IsClient() {
+		var err error
+		summary, err = ParseResult(stdout, h.spec.OutputDir)
+		if err != nil {
+			logs.Warning("iperf client read result failed, %s", err.Error())
+		}
+	}
+
+	stderr = strings.TrimSpace(stderr)
+	if stderr != "" {
+		logs.Warning("iperf3 stderr: %s", stderr)
+	}
+
+	h.exitCode = exitCode
+	h.err = runError(summary, stderr, exitCode)
+	if h.err != nil && h.err.Kind == ErrorUnknown && h.stopped.Load() {
+		h.err.Kind = ErrorInterrupted
+	}
+
+	h.summary = summary
+	if !h.spec.IsClient() {
+		h.summary = h.last
+	}
+
+	close(h.events)
+	close(h.results)
+	close(h.done)
+}
+
+func (h *runHandle) Events() <-chan IntervalEvent {
+	return h.events
+}
+
+func (h *runHandle) Results() <-chan *RunSummary {
+	return h.results
+}
+
+func (h *runHandle) Done() <-chan struct{} {
+	return h.done
+}
+
+func (h *runHandle) Wait() (*RunSummary, *IperfError) {
+	<-h.done
+	return h.summary, h.err
+}
+
+func (h *runHandle) ExitCode() int {
+	select {
+	case <-h.done:
+		return h.exitCode
+	default:
+		return -1
+	}
+}
+
+func (h *runHandle) Stop() {
+	h.stopped.Store(true)
+	if h.stop != nil {
+		h.stop()
+		return
+	}
+	h.Cancel()
+}
+
+func (h *runHandle) Cancel() {
+	if h.cancel != nil {
+		h.cancel()
+	}
+}
+
+const (
+	jsonStreamMajor = 3
+	jsonStreamMinor = 17
+)
+
+type processEngine struct {
+	name       string
+	binary     string
+	jsonStream bool
+}
+
+func BundledEngine() Engine {
+	return &processEngine{
+		name:       EngineBundled,
+		binary:     filepath.Join(ToolDirGet(), "iperf3.exe"),
+		jsonStream: true,
+	}
+}
+
+func SystemEngine() (Engine, error) {
+	binary, err := exec.LookPath("iperf3")
+	if err != nil {
+		return nil, err
+	}
+
+	engine := &processEngine{
+		name:   EngineSystem,
+		binary: binary,
+	}
+
+	major, minor, err := ProbeVersion(binary)
+	if err != nil {
+		logs.Warning("iperf3 %s version probe fail, %s", binary, err.Error())
+	} else {
+		logs.Info("iperf3 %s version %d.%d", binary, major, minor)
+	}
+	engine.jsonStream = major > jsonStreamMajor || (major == jsonStreamMajor && minor >= jsonStreamMinor)
+
+	return engine, nil
+}
+
+func (e *processEngine) args(spec TestSpec) []string {
+	args := spec.Args()
+	if e.jsonStream {
+		return args
+	}
+	for i, arg := range args {
+		if arg == "--json-stream" {
+			logs.Info("iperf3 %s has no json stream, use -J without live intervals", e.binary)
+			args[i] = "-J"
+		}
+	}
+	return args
+}
+
+func (e *processEngine) Name() string {
+	return e.name
+}
+
+func (e *processEngine) Run(spec TestSpec) (RunHandle, error) {
+	handle := newRunHandle(spec)
+
+	output := ProcessOutput{Limit: ProcessStdoutLimit, Line: handle.parseLine}
+	if !spec.IsClient() {
+		output = ProcessOutput{Limit: ProcessServerStdoutLimit, Tail: true, Line: handle.parseLine}
+	}
+
+	proc, err := StartProcess(e.binary, e.args(spec), output)
+	if err != nil {
+		return nil, err
+	}
+	handle.cancel = proc.Kill
+	handle.stop = func() {
+		proc.Stop(ProcessStopGrace)
+	}
+
+	go func() {
+		exitCode := proc.Wait()
+		handle.finish(proc.Stdout(), proc.Stderr(), exitCode)
+	}()
+
+	return handle, nil
+}
+
+type FakeEngine struct {
+	Stdout   []byte
+	Stderr   string
+	ExitCode int
+	Delay    time.Duration
+	Err      error
+}
+
+func (e *FakeEngine) Name() string {
+	return EngineFake
+}
+
+func (e *FakeEngine) Run(spec TestSpec) (RunHandle, error) {
+	if e.Err != nil {
+		return nil, e.Err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handle := newRunHandle(spec)
+	handle.cancel = cancel
+
+	go func() {
+		defer cancel()
+
+		output := strings.Builder{}
+		for _, line := range strings.SplitAfter(string(e.Stdout), "\n") {
+			select {
+			case <-ctx.Done():
+				handle.finish([]byte(output.String()), "iperf3: interrupt - the client has terminated", 1)
+				return
+			case <-time.After(e.Delay):
+			}
+			output.WriteString(line)
+			handle.parseLine(strings.TrimRight(line, "\r\n"))
+		}
+
+		handle.finish(e.Stdout, e.Stderr, e.ExitCode)
+	}()
+
+	return handle, nil
+}
@@ -0,0 +1,305 @@
+package iperf3
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func fakeClientOutput(intervals int, bitsPerSecond float64) []byte {
+	builder := strings.Builder{}
+	builder.WriteString(`{"event":"start","data":{"connecting_to":{"host":"127.0.0.1","port":5201},` +
+		`"timestamp":{"timesecs":1700000000},"test_start":{"protocol":"TCP","num_streams":1,"duration":3}}}` + "\n")
+
+	bytes := int64(bitsPerSecond / 8)
+	for i := 0; i < intervals; i++ {
+		fmt.Fprintf(&builder, `{"event":"interval","data":{"streams":[{"socket":5,"start":%d,"end":%d,"seconds":1,`+
+			`"bytes":%d,"bits_per_second":%.0f,"sender":true}],"sum":{"start":%d,"end":%d,"seconds":1,`+
+			`"bytes":%d,"bits_per_second":%.0f,"sender":true}}}`+"\n",
+			i, i+1, bytes, bitsPerSecond, i, i+1, bytes, bitsPerSecond)
+	}
+
+	fmt.Fprintf(&builder, `{"event":"end","data":{"sum_sent":{"start":0,"end":%d,"seconds":%d,"bytes":%d,`+
+		`"bits_per_second":%.0f,"sender":true},"sum_received":{"start":0,"end":%d,"seconds":%d,"bytes":%d,`+
+		`"bits_per_second":%.0f,"sender":true}}}`+"\n",
+		intervals, intervals, bytes*int64(intervals), bitsPerSecond,
+		intervals, intervals, bytes*int64(intervals), bitsPerSecond)
+
+	return []byte(builder.String())
+}
+
+func fakeClientConfig(dir string) Config {
+	config := configCache
+	config.ClientAddress = "127.0.0.1"
+	config.ClientPort = 5201
+	config.ClientStreams = 1
+	config.ClientRunTime = 3
+	config.ClientJsonFormat = true
+	config.ClientLog = dir
+	return config
+}
+
+func TestParseIperfVersion(t *testing.T) {
+	cases := []struct {
+		text  string
+		major int
+		minor int
+		ok    bool
+	}{
+		{"iperf 3.18 (cJSON 1.7.15)\nWindows", 3, 18, true},
+		{"iperf 3.9 (cJSON 1.7.13)", 3, 9, true},
+		{"iperf3: parameter error", 0, 0, false},
+	}
+	for _, item := range cases {
+		major, minor, ok := parseIperfVersion(item.text)
+		if major != item.major || minor != item.minor || ok != item.ok {
+			t.Errorf("parseIperfVersion(%q) = %d.%d %t, want %d.%d %t",
+				item.text, major, minor, ok, item.major, item.minor, item.ok)
+		}
+	}
+}
+
+func TestProcessEngineJsonFallback(t *testing.T) {
+	spec := NewClientSpec(fakeClientConfig(t.TempDir()))
+
+	engine := &processEngine{name: EngineSystem, binary: "iperf3", jsonStream: false}
+	args := strings.Join(engine.args(spec), " ")
+	if strings.Contains(args, "--json-stream") || !strings.Contains(args, "-J") {
+		t.Errorf("old iperf3 args %q, want -J instead of --json-stream", args)
+	}
+
+	engine.jsonStream = true
+	args = strings.Join(engine.args(spec), " ")
+	if !strings.Contains(args, "--json-stream") {
+		t.Errorf("new iperf3 args %q, want --json-stream", args)
+	}
+}
+
+func TestClientStartupFakeEngine(t *testing.T) {
+	dir := t.TempDir()
+	DataDirSet(dir)
+	defer DataDirSet("")
+
+	engine := &FakeEngine{Stdout: fakeClientOutput(3, 1e9)}
+
+	srv, err := ClientStartup(engine, fakeClientConfig(dir), 0)
+	if err != nil {
+		t.Fatalf("client startup fail, %s", err.Error())
+	}
+	summary, runErr := srv.Wait()
+	if runErr != nil {
+		t.Fatalf("client run fail, %s", runErr.Error())
+	}
+	if summary == nil {
+		t.Fatal("client run has no summary")
+	}
+	if summary.ReceivedBitsPerSecond != 1e9 {
+		t.Errorf("received %.0f bits/sec, want 1e9", summary.ReceivedBitsPerSecond)
+	}
+	if summary.Target != "127.0.0.1:5201" {
+		t.Errorf("target %q, want 127.0.0.1:5201", summary.Target)
+	}
+	if summary.File == "" || filepath.Dir(summary.File) != dir {
+		t.Errorf("result file %q not saved in %s", summary.File, dir)
+	}
+
+	entries, err := HistoryFind(HistoryQuery{Role: RoleClient})
+	if err != nil {
+		t.Fatalf("history find fail, %s", err.Error())
+	}
+	if len(entries) != 1 || entries[0].Status != HistoryStatusOK {
+		t.Fatalf("history entries %v, want one ok entry", entries)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "history.jsonl")); err != nil {
+		t.Errorf("history not written to data dir, %s", err.Error())
+	}
+}
+
+func TestClientStartupFakeEngineUnreachable(t *testing.T) {
+	dir := t.TempDir()
+	DataDirSet(dir)
+	defer DataDirSet("")
+
+	engine := &FakeEngine{
+		Stderr:   "iperf3: error - unable to connect to server - server may have stopped running or use a different port",
+		ExitCode: 1,
+	}
+
+	srv, err := ClientStartup(engine, fakeClientConfig(dir), 0)
+	if err != nil {
+		t.Fatalf("client startup fail, %s", err.Error())
+	}
+
+	_, runErr := srv.Wait()
+	if runErr == nil || runErr.Kind != ErrorUnreachable || !runErr.Retryable() {
+		t.Fatalf("run error %v, want retryable %s", runErr, ErrorUnreachable)
+	}
+
+	entries, err := HistoryFind(HistoryQuery{Status: HistoryStatusFailed})
+	if err != nil {
+		t.Fatalf("history find fail, %s", err.Error())
+	}
+	if len(entries) != 1 {
+		t.Fatalf("history has %d failed entries, want 1", len(entries))
+	}
+}
+
+func fakeServerOutput(host string) string {
+	return `{
+	"start":	{
+		"connected":	[{
+				"socket":	5,
+				"local_host":	"127.0.0.1",
+				"local_port":	5201,
+				"remote_host":	"` + host + `",
+				"remote_port":	50312
+			}],
+		"version":	"iperf 3.18",
+		"system_info":	"CYGWIN_NT-10.0-19045 3.5.4-1.x86_64 2024-08-25 16:52 UTC x86_64",
+		"sock_bufsize":	0,
+		"sndbuf_actual":	65536,
+		"rcvbuf_actual":	65536,
+		"timestamp":	{
+			"time":	"Mon, 13 Nov 2023 22:13:20 GMT",
+			"timesecs":	1699913600
+		},
+		"accepted_connection":	{
+			"host":	"` + host + `",
+			"port":	50310
+		},
+		"cookie":	"yhy4ldgyrhzyqqlxjxm5uxbxfv5ku3rjl7mv",
+		"tcp_mss_default":	1460,
+		"test_start":	{
+			"protocol":	"TCP",
+			"num_streams":	1,
+			"blksize":	131072,
+			"omit":	0,
+			"duration":	3,
+			"bytes":	0,
+			"blocks":	0,
+			"reverse":	0,
+			"tos":	0,
+			"target_bitrate":	0,
+			"bidir":	0,
+			"fqrate":	0,
+			"interval":	1
+		}
+	},
+	"intervals":	[{
+			"streams":	[{
+					"socket":	5,
+					"start":	0,
+					"end":	1,
+					"seconds":	1,
+					"bytes":	117500000,
+					"bits_per_second":	940000000,
+					"omitted":	false,
+					"sender":	false
+				}],
+			"sum":	{
+				"start":	0,
+				"end":	1,
+				"seconds":	1,
+				"bytes":	117500000,
+				"bits_per_second":	940000000,
+				"omitted":	false,
+				"sender":	false
+			}
+		}],
+	"end":	{
+		"streams":	[{
+				"sender":	{
+					"socket":	5,
+					"start":	0,
+					"end":	3,
+					"seconds":	3,
+					"bytes":	352500000,
+					"bits_per_second":	940000000,
+					"sender":	false
+				},
+				"receiver":	{
+					"socket":	5,
+					"start":	0,
+					"end":	3,
+					"seconds":	3,
+					"bytes":	352500000,
+					"bits_per_second":	940000000,
+					"sender":	false
+				}
+			}],
+		"sum_sent":	{
+			"start":	0,
+			"end":	3,
+			"seconds":	3,
+			"bytes":	352500000,
+			"bits_per_second":	940000000,
+			"retransmits":	0,
+			"sender":	false
+		},
+		"sum_received":	{
+			"start":	0,
+			"end":	3,
+			"seconds":	3,
+			"bytes":	352500000,
+			"bits_per_second":	940000000,
+			"sender":	false
+		},
+		"cpu_utilization_percent":	{
+			"host_total":	12.5,
+			"host_user":	2.1,
+			"host_system":	10.4,
+			"remote_total":	8.2,
+			"remote_user":	1.3,
+			"remote_system":	6.9
+		},
+		"receiver_tcp_congestion":	"cubic"
+	}
+}
+`
+}
+
+func TestServerStartupFakeEngineResults(t *testing.T) {
+	dir := t.TempDir()
+	DataDirSet(dir)
+	defer DataDirSet("")
+
+	config := configCache
+	config.ServerListen = "127.0.0.1"
+	config.ServerPort = 5201
+	config.ServerJsonFormat = true
+	config.ServerLog = dir
+
+	engine := &FakeEngine{Stdout: []byte(fakeServerOutput("10.0.0.1") + fakeServerOutput("10.0.0.2"))}
+
+	srv, err := ServerStartup(engine, config, 0)
+	if err != nil {
+		t.Fatalf("server startup fail, %s", err.Error())
+	}
+
+	summary, runErr := srv.Wait()
+	if runErr != nil {
+		t.Fatalf("server run fail, %s", runErr.Error())
+	}
+	if summary == nil || summary.Target != "10.0.0.2" {
+		t.Fatalf("server summary %v, want last test from 10.0.0.2", summary)
+	}
+	if summary.Upload == nil || summary.Download != nil || summary.Upload.ReceivedBitsPerSecond != 940e6 {
+		t.Errorf("server directions up %v down %v, want the client upload received at 940Mbps",
+			summary.Upload, summary.Download)
+	}
+
+	entries, err := HistoryFind(HistoryQuery{Role: RoleServer})
+	if err != nil {
+		t.Fatalf("history find fail, %s", err.Error())
+	}
+	if len(entries) != 2 {
+		t.Fatalf("history has %d server entries, want one per test", len(entries))
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "iperf3_*.json"))
+	if len(files) != 2 || entries[0].File == entries[1].File {
+		t.Errorf("server results saved as %v, want one file per test", files)
+	}
+}
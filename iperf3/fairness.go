@@ -0,0 +1,164 @@
+package iperf3
+
+import (
+	"sort"
+)
+
+const StarveShare = 0.25
+
+type StreamShare struct {
+	Socket        int64   `json:"socket"`
+	BitsPerSecond float64 `json:"bits_per_second"`
+	Share         float64 `json:"share"`
+}
+
+type IntervalFairness struct {
+	Start     float64 `json:"start"`
+	End       float64 `json:"end"`
+	JainIndex float64 `json:"jain_index"`
+	Spread    float64 `json:"spread"`
+	Starved   []int64 `json:"starved,omitempty"`
+}
+
+type FairnessReport struct {
+	Direction string             `json:"direction"`
+	Streams   int                `json:"streams"`
+	JainIndex float64            `json:"jain_index"`
+	Min       float64            `json:"min_bits_per_second"`
+	Max       float64            `json:"max_bits_per_second"`
+	Spread    float64            `json:"spread"`
+	Shares    []StreamShare      `json:"shares"`
+	Intervals []IntervalFairness `json:"intervals"`
+	Starved   int                `json:"starved_intervals"`
+}
+
+func JainIndex(values []float64) float64 {
+	var sum, square float64
+	for _, v := range values {
+		sum += v
+		square += v * v
+	}
+	if square == 0 {
+		return 0
+	}
+	return sum * sum / (float64(len(values)) * square)
+}
+
+func spreadOf(values []float64) (float64, float64, float64) {
+	if len(values) == 0 {
+		return 0, 0, 0
+	}
+	min, max, sum := values[0], values[0], 0.0
+	for _, v := range values {
+		if v < min {
+			min = v
+		}
+		if v > max {
+			max = v
+		}
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if mean == 0 {
+		return min, max, 0
+	}
+	return min, max, (max - min) / mean
+}
+
+func endStreamRate(result *Result, stream *StreamResult) float64 {
+	if result.IsUDP() {
+		return stream.Udp.BitPerSecond
+	}
+	if stream.Receiver.BitPerSecond > 0 {
+		return stream.Receiver.BitPerSecond
+	}
+	return stream.Sender.BitPerSecond
+}
+
+func newFairnessReport(result *Result, direction string) *FairnessReport {
+	report := &FairnessReport{
+		Direction: direction,
+		Shares:    make([]StreamShare, 0),
+		Intervals: make([]IntervalFairness, 0),
+	}
+
+	values := make([]float64, 0)
+	var total float64
+	for i := range result.End.Streams {
+		stream := &result.End.Streams[i]
+		if stream.Sender.Direction != direction {
+			continue
+		}
+		rate := endStreamRate(result, stream)
+		socket := stream.Sender.Socket
+		if result.IsUDP() {
+			socket = stream.Udp.Socket
+		}
+		report.Shares = append(report.Shares, StreamShare{Socket: socket, BitsPerSecond: rate})
+		values = append(values, rate)
+		total += rate
+	}
+
+	report.Streams = len(values)
+	if report.Streams < 2 {
+		return nil
+	}
+
+	for i := range report.Shares {
+		if total > 0 {
+			report.Shares[i].Share = report.Shares[i].BitsPerSecond / total
+		}
+	}
+	sort.Slice(report.Shares, func(i, j int) bool {
+		return report.Shares[i].Socket < report.Shares[j].Socket
+	})
+
+	report.JainIndex = JainIndex(values)
+	report.Min, report.Max, report.Spread = spreadOf(values)
+
+	for _, interval := range result.Intervals {
+		item := IntervalFairness{Start: interval.Sum.Start, End: interval.Sum.End}
+		rates := make([]float64, 0)
+		sockets := make([]int64, 0)
+		var sum float64
+		for _, stream := range interval.Streams {
+			if stream.Direction != direction || stream.Omitted {
+				continue
+			}
+			item.Start, item.End = stream.Start, stream.End
+			rates = append(rates, stream.BitPerSecond)
+			sockets = append(sockets, stream.Socket)
+			sum += stream.BitPerSecond
+		}
+		if len(rates) < 2 {
+			continue
+		}
+
+		item.JainIndex = JainIndex(rates)
+		_, _, item.Spread = spreadOf(rates)
+
+		fair := sum / float64(len(rates))
+		for i, rate := range rates {
+			if rate < fair*StarveShare {
+				item.Starved = append(item.Starved, sockets[i])
+			}
+		}
+		if len(item.Starved) > 0 {
+			report.Starved++
+		}
+
+		report.Intervals = append(report.Intervals, item)
+	}
+
+	return report
+}
+
+func NewFairnessReports(result *Result) []*FairnessReport {
+	reports := make([]*FairnessReport, 0)
+	for _, direction := range []string{DirectionUpload, DirectionDownload} {
+		if report := newFairnessReport(result, direction); report != nil {
+			reports = append(reports, report)
+		}
+	}
+	return reports
+}
@@ -0,0 +1,86 @@
+package iperf3
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ErrorKind string
+
+const (
+	ErrorServerBusy      ErrorKind = "server_busy"
+	ErrorUnreachable     ErrorKind = "unreachable"
+	ErrorAuthFailure     ErrorKind = "auth_failure"
+	ErrorInvalidArgument ErrorKind = "invalid_argument"
+	ErrorInterrupted     ErrorKind = "interrupted"
+	ErrorTimeout         ErrorKind = "timeout"
+	ErrorUnknown         ErrorKind = "unknown"
+)
+
+type IperfError struct {
+	Kind     ErrorKind
+	Message  string
+	ExitCode int
+}
+
+var errorPatterns = []struct {
+	kind     ErrorKind
+	patterns []string
+}{
+	{ErrorServerBusy, []string{"server is busy", "busy running a test"}},
+	{ErrorInterrupted, []string{"interrupt - the client has terminated"}},
+	{ErrorAuthFailure, []string{"authenticat", "authoriz", "access denied", "public key", "private key", "password"}},
+	{ErrorUnreachable, []string{"the server has terminated", "unable to connect", "connection refused", "no route to host",
+		"network is unreachable", "timed out", "connection reset", "control socket has closed",
+		"unable to receive", "unable to send", "name resolution", "unknown host"}},
+	{ErrorInvalidArgument, []string{"parameter", "invalid", "unrecognized option", "unknown option",
+		"requires an argument", "must be", "cannot be", "not supported", "bad "}},
+}
+
+func ClassifyError(message string) ErrorKind {
+	text := strings.ToLower(message)
+	for _, item := range errorPatterns {
+		for _, pattern := range item.patterns {
+			if strings.Contains(text, pattern) {
+				return item.kind
+			}
+		}
+	}
+	return ErrorUnknown
+}
+
+func NewIperfError(message string, exitCode int) *IperfError {
+	message = strings.TrimSpace(message)
+	message = strings.TrimPrefix(message, "iperf3: ")
+	message = strings.TrimPrefix(message, "error - ")
+	return &IperfError{
+		Kind:     ClassifyError(message),
+		Message:  message,
+		ExitCode: exitCode,
+	}
+}
+
+func (e *IperfError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Kind, e.Message)
+}
+
+func (e *IperfError) Retryable() bool {
+	return e.Kind == ErrorServerBusy || e.Kind == ErrorUnreachable || e.Kind == ErrorTimeout
+}
+
+func runError(summary *RunSummary, stderr string, exitCode int) *IperfError {
+	if summary != nil && summary.Error != "" {
+		return NewIperfError(summary.Error, exitCode)
+	}
+	if exitCode == 0 {
+		return nil
+	}
+	if stderr != "" {
+		return NewIperfError(stderr, exitCode)
+	}
+	return &IperfError{
+		Kind:     ErrorUnknown,
+		Message:  fmt.Sprintf("iperf3 exit code %d", exitCode),
+		ExitCode: exitCode,
+	}
+}
@@ -49,7 +49,16 @@ func IconDirGet() string {
 	return dir
 }
 
+var dataDirOverride string
+
+func DataDirSet(dir string) {
+	dataDirOverride = dir
+}
+
 func DataDirGet() string {
+	if dataDirOverride != "" {
+		return dataDirOverride
+	}
 	dir := fmt.Sprintf("%s\\data\\%s", _home, _name)
 	_, err := os.Stat(dir)
 	if err != nil {
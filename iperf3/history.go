@@ -0,0 +1,204 @@
+package iperf3
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+)
+
+const (
+	HistoryStatusOK          = "ok"
+	HistoryStatusFailed      = "failed"
+	HistoryStatusInterrupted = "interrupted"
+	HistoryStatusTimeout     = "timeout"
+)
+
+type HistoryEntry struct {
+	ID       string      `json:"id"`
+	Time     time.Time   `json:"time"`
+	Role     string      `json:"role"`
+	Target   string      `json:"target"`
+	Protocol string      `json:"protocol"`
+	Status   string      `json:"status"`
+	Error    string      `json:"error,omitempty"`
+	Config   Config      `json:"config"`
+	Summary  *RunSummary `json:"summary,omitempty"`
+	File     string      `json:"file,omitempty"`
+}
+
+type HistoryQuery struct {
+	Role     string
+	Target   string
+	Protocol string
+	Status   string
+	From     time.Time
+	To       time.Time
+	Limit    int
+}
+
+var historyLock sync.Mutex
+
+func historyFilePath() string {
+	return filepath.Join(DataDirGet(), "history.jsonl")
+}
+
+func NewHistoryEntry(role string, config Config, summary *RunSummary, runErr *IperfError) *HistoryEntry {
+	entry := &HistoryEntry{
+		ID:       fmt.Sprintf("%d", GetTimestampUS()),
+		Time:     time.Now(),
+		Role:     role,
+		Protocol: config.ClientProtocol,
+		Status:   HistoryStatusOK,
+		Config:   config.Redacted(),
+		Summary:  summary,
+	}
+
+	if role == "client" {
+		entry.Target = fmt.Sprintf("%s:%d", config.ClientAddress, config.ClientPort)
+	}
+
+	if summary != nil {
+		entry.File = summary.File
+		if summary.Target != "" {
+			entry.Target = summary.Target
+		}
+		if summary.Protocol != "" {
+			entry.Protocol = summary.Protocol
+		}
+	}
+
+	if runErr != nil {
+		entry.Status = HistoryStatusFailed
+		entry.Error = runErr.Error()
+		if summary != nil && summary.Interrupted {
+			entry.Status = HistoryStatusInterrupted
+		}
+		if runErr.Kind == ErrorTimeout {
+			entry.Status = HistoryStatusTimeout
+		}
+	}
+
+	return entry
+}
+
+func HistoryAppend(entry *HistoryEntry) error {
+	historyLock.Lock()
+	defer historyLock.Unlock()
+
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(historyFilePath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0664)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(value, '\n'))
+	return err
+}
+
+func historyRecord(entry *HistoryEntry) {
+	if err := HistoryAppend(entry); err != nil {
+		logs.Warning("history append fail, %s", err.Error())
+	}
+}
+
+func historyTargetHost(target string) string {
+	index := strings.LastIndex(target, ":")
+	if index < 0 {
+		return target
+	}
+	if _, err := strconv.Atoi(target[index+1:]); err != nil {
+		return target
+	}
+	return target[:index]
+}
+
+func (q *HistoryQuery) match(entry *HistoryEntry) bool {
+	if q.Role != "" && q.Role != entry.Role {
+		return false
+	}
+	if q.Target != "" && q.Target != entry.Target && q.Target != historyTargetHost(entry.Target) {
+		return false
+	}
+	if q.Protocol != "" && !strings.EqualFold(q.Protocol, entry.Protocol) {
+		return false
+	}
+	if q.Status != "" && q.Status != entry.Status {
+		return false
+	}
+	if !q.From.IsZero() && entry.Time.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && entry.Time.After(q.To) {
+		return false
+	}
+	return true
+}
+
+func HistoryFind(query HistoryQuery) ([]*HistoryEntry, error) {
+	historyLock.Lock()
+	defer historyLock.Unlock()
+
+	output := make([]*HistoryEntry, 0)
+
+	file, err := os.Open(historyFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return output, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		entry := new(HistoryEntry)
+		if err := json.Unmarshal(line, entry); err != nil {
+			logs.Warning("history entry invalid, %s", err.Error())
+			continue
+		}
+		if query.match(entry) {
+			output = append(output, entry)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if query.Limit > 0 && len(output) > query.Limit {
+		output = output[len(output)-query.Limit:]
+	}
+
+	return output, nil
+}
+
+func HistoryGet(id string) (*HistoryEntry, error) {
+	entries, err := HistoryFind(HistoryQuery{})
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.ID == id {
+			return entry, nil
+		}
+	}
+	return nil, fmt.Errorf("history entry %s not found", id)
+}
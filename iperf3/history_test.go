@@ -0,0 +1,24 @@
+package iperf3
+
+import "testing"
+
+func TestHistoryQueryTarget(t *testing.T) {
+	cases := []struct {
+		query  string
+		target string
+		match  bool
+	}{
+		{"10.0.0.1", "10.0.0.1:5201", true},
+		{"10.0.0.1", "10.0.0.10:5201", false},
+		{"10.0.0.1:5201", "10.0.0.1:5201", true},
+		{"10.0.0.1:5201", "10.0.0.1:52010", false},
+		{"10.0.0.1", "10.0.0.1", true},
+		{"10.0.0.1", "10.0.0.100", false},
+	}
+	for _, item := range cases {
+		query := HistoryQuery{Target: item.query}
+		if query.match(&HistoryEntry{Target: item.target}) != item.match {
+			t.Errorf("query %q on target %q, want match %t", item.query, item.target, item.match)
+		}
+	}
+}
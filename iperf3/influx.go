@@ -0,0 +1,258 @@
+package iperf3
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+)
+
+const (
+	InfluxBatchSize     = 500
+	InfluxFlushInterval = 5 * time.Second
+	InfluxMaxPending    = 100000
+	InfluxMaxBackoff    = time.Minute
+)
+
+type InfluxExporter struct {
+	file    string
+	url     string
+	token   string
+	client  *http.Client
+	flush   sync.Mutex
+	lock    sync.Mutex
+	pending []string
+	notify  chan struct{}
+}
+
+var influxExporter *InfluxExporter
+var influxHost string
+
+var influxTagEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `, `=`, `\=`)
+
+func influxTags(tags ...string) string {
+	builder := strings.Builder{}
+	for i := 0; i+1 < len(tags); i += 2 {
+		if tags[i+1] == "" {
+			continue
+		}
+		fmt.Fprintf(&builder, ",%s=%s", tags[i], influxTagEscaper.Replace(tags[i+1]))
+	}
+	return builder.String()
+}
+
+func influxFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func InfluxIntervalLines(host string, event IntervalEvent) []string {
+	lines := make([]string, 0)
+	for _, direction := range []string{DirectionUpload, DirectionDownload} {
+		sum := event.Upload
+		if direction == DirectionDownload {
+			sum = event.Download
+		}
+		if sum == nil {
+			continue
+		}
+		streams := 0
+		for _, stream := range event.Interval.Streams {
+			if stream.Direction == direction {
+				streams++
+			}
+		}
+		tags := influxTags("host", host, "target", event.Target, "protocol", event.Protocol,
+			"streams", strconv.Itoa(streams), "direction", direction)
+		fields := fmt.Sprintf("bytes=%di,bits_per_second=%s,retransmits=%di,jitter_ms=%s,lost_packets=%di,lost_percent=%s,omitted=%t",
+			sum.Bytes, influxFloat(sum.BitPerSecond), sum.Retransmits, influxFloat(sum.JitterMs),
+			sum.LostPackets, influxFloat(sum.LostPercent), sum.Omitted)
+		lines = append(lines, fmt.Sprintf("iperf3_interval%s %s %d", tags, fields, event.Time.UnixNano()))
+	}
+	return lines
+}
+
+func InfluxSummaryLines(host string, summary *RunSummary, timestamp time.Time) []string {
+	lines := make([]string, 0)
+	for _, direction := range []*DirectionSummary{summary.Upload, summary.Download} {
+		if direction == nil {
+			continue
+		}
+		tags := influxTags("host", host, "target", summary.Target, "protocol", summary.Protocol,
+			"streams", strconv.FormatInt(direction.Streams, 10), "direction", direction.Direction)
+		fields := fmt.Sprintf("sent_bytes=%di,received_bytes=%di,sent_bits_per_second=%s,received_bits_per_second=%s,"+
+			"retransmits=%di,jitter_ms=%s,lost_percent=%s,duration=%s,host_cpu=%s,remote_cpu=%s",
+			direction.SentBytes, direction.ReceivedBytes, influxFloat(direction.SentBitsPerSecond),
+			influxFloat(direction.ReceivedBitsPerSecond), direction.Retransmits, influxFloat(direction.JitterMs),
+			influxFloat(direction.LostPercent), influxFloat(summary.Duration), influxFloat(summary.HostCpu),
+			influxFloat(summary.RemoteCpu))
+		lines = append(lines, fmt.Sprintf("iperf3_summary%s %s %d", tags, fields, timestamp.UnixNano()))
+	}
+	return lines
+}
+
+func NewInfluxExporter(file, url, token string) *InfluxExporter {
+	exporter := &InfluxExporter{
+		file:    file,
+		url:     url,
+		token:   token,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		pending: make([]string, 0),
+		notify:  make(chan struct{}, 1),
+	}
+	if url != "" {
+		go exporter.run()
+	}
+	return exporter
+}
+
+func (e *InfluxExporter) Write(lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+
+	if e.file != "" {
+		err := e.writeFile(lines)
+		if err != nil {
+			logs.Warning("influx write file %s fail, %s", e.file, err.Error())
+		}
+	}
+
+	if e.url == "" {
+		return
+	}
+
+	e.lock.Lock()
+	e.pending = append(e.pending, lines...)
+	if len(e.pending) > InfluxMaxPending {
+		drop := len(e.pending) - InfluxMaxPending
+		logs.Warning("influx pending lines over limit, drop %d oldest lines", drop)
+		e.pending = e.pending[drop:]
+	}
+	full := len(e.pending) >= InfluxBatchSize
+	e.lock.Unlock()
+
+	if full {
+		select {
+		case e.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (e *InfluxExporter) writeFile(lines []string) error {
+	file, err := os.OpenFile(e.file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0664)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(strings.Join(lines, "\n") + "\n")
+	return err
+}
+
+func (e *InfluxExporter) post(lines []string) error {
+	request, err := http.NewRequest(http.MethodPost, e.url, bytes.NewBufferString(strings.Join(lines, "\n")))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if e.token != "" {
+		request.Header.Set("Authorization", "Token "+e.token)
+	}
+
+	response, err := e.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("influx write status %s", response.Status)
+	}
+	return nil
+}
+
+func (e *InfluxExporter) Flush() error {
+	e.flush.Lock()
+	defer e.flush.Unlock()
+
+	for {
+		e.lock.Lock()
+		count := len(e.pending)
+		if count > InfluxBatchSize {
+			count = InfluxBatchSize
+		}
+		batch := append([]string{}, e.pending[:count]...)
+		e.lock.Unlock()
+
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := e.post(batch); err != nil {
+			return err
+		}
+
+		e.lock.Lock()
+		e.pending = e.pending[count:]
+		e.lock.Unlock()
+	}
+}
+
+func (e *InfluxExporter) run() {
+	backoff := InfluxFlushInterval
+	for {
+		select {
+		case <-e.notify:
+		case <-time.After(backoff):
+		}
+
+		err := e.Flush()
+		if err != nil {
+			backoff *= 2
+			if backoff > InfluxMaxBackoff {
+				backoff = InfluxMaxBackoff
+			}
+			logs.Warning("influx write fail, retry after %s, %s", backoff, err.Error())
+			continue
+		}
+		backoff = InfluxFlushInterval
+	}
+}
+
+func influxRecord(summary *RunSummary) {
+	if influxExporter == nil || summary == nil || summary.Failed() {
+		return
+	}
+	influxExporter.Write(InfluxSummaryLines(influxHost, summary, time.Now()))
+}
+
+func InfluxInit() {
+	if configCache.InfluxFile == "" && configCache.InfluxURL == "" {
+		return
+	}
+
+	influxHost, _ = os.Hostname()
+	influxExporter = NewInfluxExporter(configCache.InfluxFile, configCache.InfluxURL, configCache.InfluxToken)
+
+	IntervalSubscribe(func(event IntervalEvent) {
+		influxExporter.Write(InfluxIntervalLines(influxHost, event))
+	})
+
+	logs.Info("influx exporter file %s url %s", configCache.InfluxFile, configCache.InfluxURL)
+}
+
+func InfluxClose() {
+	if influxExporter == nil || influxExporter.url == "" {
+		return
+	}
+	if err := influxExporter.Flush(); err != nil {
+		logs.Warning("influx flush on close fail, %s", err.Error())
+	}
+}
@@ -1,15 +1,11 @@
 package iperf3
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
-	"syscall"
-	"time"
 
 	"github.com/astaxie/beego/logs"
 )
@@ -41,6 +37,7 @@ type TestConfig struct {
 	Bytes      int64  `json:"bytes"`
 	Blocks     int64  `json:"blocks"`
 	Reverse    int64  `json:"reverse"`
+	Bidir      int64  `json:"bidir"`
 }
 
 type Start struct {
@@ -62,6 +59,24 @@ type Stream struct {
 	Bytes        int64   `json:"bytes"`
 	BitPerSecond float64 `json:"bits_per_second"`
 	Omitted      bool    `json:"omitted"`
+	JitterMs     float64 `json:"jitter_ms"`
+	LostPackets  int64   `json:"lost_packets"`
+	Packets      int64   `json:"packets"`
+	LostPercent  float64 `json:"lost_percent"`
+	OutOfOrder   int64   `json:"out_of_order"`
+	Retransmits  int64   `json:"retransmits"`
+	SndCwnd      int64   `json:"snd_cwnd"`
+	SndWnd       int64   `json:"snd_wnd"`
+	Rtt          int64   `json:"rtt"`
+	RttVar       int64   `json:"rttvar"`
+	Pmtu         int64   `json:"pmtu"`
+	MaxSndCwnd   int64   `json:"max_snd_cwnd"`
+	MaxSndWnd    int64   `json:"max_snd_wnd"`
+	MaxRtt       int64   `json:"max_rtt"`
+	MinRtt       int64   `json:"min_rtt"`
+	MeanRtt      int64   `json:"mean_rtt"`
+	Sender       bool    `json:"sender"`
+	Direction    string  `json:"-"`
 }
 
 type Sum struct {
@@ -71,11 +86,18 @@ type Sum struct {
 	Bytes        int64   `json:"bytes"`
 	BitPerSecond float64 `json:"bits_per_second"`
 	Omitted      bool    `json:"omitted"`
+	JitterMs     float64 `json:"jitter_ms"`
+	LostPackets  int64   `json:"lost_packets"`
+	Packets      int64   `json:"packets"`
+	LostPercent  float64 `json:"lost_percent"`
+	Retransmits  int64   `json:"retransmits"`
+	Sender       bool    `json:"sender"`
 }
 
 type Interval struct {
-	Streams []Stream `json:"streams"`
-	Sum     Sum      `json:"sum"`
+	Streams         []Stream `json:"streams"`
+	Sum             Sum      `json:"sum"`
+	SumBidirReverse Sum      `json:"sum_bidir_reverse"`
 }
 
 type CpuUtilPercent struct {
@@ -90,270 +112,288 @@ type CpuUtilPercent struct {
 type StreamResult struct {
 	Sender   Stream `json:"sender"`
 	Receiver Stream `json:"receiver"`
+	Udp      Stream `json:"udp"`
 }
 
 type End struct {
 	Streams     []StreamResult `json:"streams"`
+	Sum         Sum            `json:"sum"`
 	SumSender   Sum            `json:"sum_sent"`
 	SumReceiver Sum            `json:"sum_received"`
 	CpuPercent  CpuUtilPercent `json:"cpu_utilization_percent"`
+
+	SumSenderBidirReverse   Sum `json:"sum_sent_bidir_reverse"`
+	SumReceiverBidirReverse Sum `json:"sum_received_bidir_reverse"`
+
+	SenderHasRetransmits  int64  `json:"sender_has_retransmits"`
+	SenderTcpCongestion   string `json:"sender_tcp_congestion"`
+	ReceiverTcpCongestion string `json:"receiver_tcp_congestion"`
+}
+
+func (r *Result) IsUDP() bool {
+	return strings.EqualFold(r.Start.TestStart.Protocol, "udp")
 }
 
 type Result struct {
 	Start     Start      `json:"start"`
 	End       End        `json:"end"`
 	Intervals []Interval `json:"intervals"`
+	Error     string     `json:"error"`
+
+	ServerOutputJson *Result `json:"server_output_json"`
+	ServerOutputText string  `json:"server_output_text"`
 }
 
 type IperfServer struct {
-	running  bool
-	exitCode int
-	stdOut   string
-	stdErr   string
-	cancel   context.CancelFunc
+	handle  RunHandle
+	done    chan struct{}
+	summary *RunSummary
+	err     *IperfError
 }
 
-func ExecuteAsync(binary string, cmd []string) (*os.File, *os.File, context.CancelFunc, chan int, error) {
-	logs.Info("ExecuteAsync %s %v", binary, cmd)
-
-	stdoutTmp, err := os.CreateTemp("", "iperf3_win_stdout_*.json")
-	if err != nil {
-		return nil, nil, nil, nil, err
-	}
-
-	stderrTmp, err := os.CreateTemp("", "iperf3_win_stderr_*.json")
-	if err != nil {
-		return nil, nil, nil, nil, err
-	}
-
-	ctx, cancel := context.WithCancel(context.Background())
-	exe := exec.CommandContext(ctx, binary, cmd...)
-	exe.SysProcAttr = &syscall.SysProcAttr{
-		HideWindow: true,
+func newIperfServer(handle RunHandle) *IperfServer {
+	return &IperfServer{
+		handle: handle,
+		done:   make(chan struct{}),
 	}
+}
 
-	exe.Stdout = stdoutTmp
-	exe.Stderr = stderrTmp
-
-	err = exe.Start()
-	if err != nil {
-		defer cancel()
-		return nil, nil, nil, nil, err
+func (s *IperfServer) Running() bool {
+	select {
+	case <-s.done:
+		return false
+	default:
+		return true
 	}
+}
 
-	exitCodeChan := make(chan int, 1)
-
-	go func() {
-		defer stdoutTmp.Close()
-		defer stderrTmp.Close()
-
-		exe.Wait()
-
-		logs.Info("iperf3.exe exit code %d", exe.ProcessState.ExitCode())
+func (s *IperfServer) Done() <-chan struct{} {
+	return s.done
+}
 
-		exitCodeChan <- exe.ProcessState.ExitCode()
-	}()
+func (s *IperfServer) Wait() (*RunSummary, *IperfError) {
+	<-s.done
+	return s.summary, s.err
+}
 
-	return stdoutTmp, stderrTmp, cancel, exitCodeChan, nil
+func (s *IperfServer) ExitCode() int {
+	return s.handle.ExitCode()
 }
 
 func (s *IperfServer) Shutdown() {
-	if s.running && s.cancel != nil {
-		s.cancel()
-		time.Sleep(100 * time.Millisecond)
+	if s.Running() {
+		s.handle.Stop()
 		logs.Info("shutdown iperf3.exe")
 	}
 }
 
-func ReadResult(filePath string, outputDir string) {
-	text, err := os.ReadFile(filePath)
-	if err != nil {
-		logs.Error("read file %s failed, %s", filePath, err.Error())
-		return
+func (s *IperfServer) Summary() *RunSummary {
+	return s.summary
+}
+
+func (s *IperfServer) Err() *IperfError {
+	return s.err
+}
+
+func ParseResult(text []byte, outputDir string) (*RunSummary, error) {
+	var err error
+	if isJSONStream(text) {
+		text, err = JSONStreamAssemble(text)
+		if err != nil {
+			logs.Error("json stream assemble fail, %s", err.Error())
+			return nil, err
+		}
 	}
 
 	if !json.Valid(text) {
 		logs.Error("json invalid, %s", string(text))
-		return
+		return nil, fmt.Errorf("iperf3 output is not json")
 	}
 
 	text, err = FormatJSON(text)
 	if err != nil {
 		logs.Warning("json format fail, %s", err.Error())
-		return
+		return nil, err
 	}
 
 	logs.Info("iperf3 result: %s", string(text))
 
+	var file string
 	if outputDir != "" {
-		SaveToFile(filepath.Join(outputDir, fmt.Sprintf("iperf3_%s.json", GetTimestamp())), text)
+		file = filepath.Join(outputDir, fmt.Sprintf("iperf3_%s.json", GetTimestampMicro()))
+		if err := SaveToFile(file, text); err != nil {
+			logs.Warning("save result to %s fail, %s", file, err.Error())
+			file = ""
+		}
 	}
 
 	var result Result
 	if err := json.Unmarshal(text, &result); err != nil {
 		logs.Info("json unmarshal fail, %s", err.Error())
+		return nil, err
 	}
-	// TBD
-}
 
-func ServerStartup(index int) (*IperfServer, error) {
-	value, err := json.Marshal(configCache)
-	if err != nil {
-		logs.Error("json marshal config fail, %s", err.Error())
-	} else {
-		logs.Info("iperf server options %s", string(value))
-	}
+	result.LabelDirections()
+
+	summary := NewRunSummary(&result)
+	summary.File = file
 
-	builder := strings.Builder{}
-	builder.WriteString(" -s")
+	logs.Info("iperf3 summary: %s", summary.String())
 
-	fmt.Fprintf(&builder, " -B %s", configCache.ServerListen)
-	fmt.Fprintf(&builder, " --port %d", configCache.ServerPort+index)
+	if summary.Server != nil {
+		logs.Info("iperf3 client and server view:\n%s", summary.ServerView())
+		for _, item := range summary.Disagreements {
+			logs.Warning("iperf3 client and server disagree, %s", item)
+		}
+	} else if result.ServerOutputText != "" {
+		logs.Info("iperf3 server output: %s", result.ServerOutputText)
+	}
 
-	if configCache.ServerInterval > 0 {
-		fmt.Fprintf(&builder, " --interval %d", configCache.ServerInterval)
+	for _, fairness := range summary.Fairness {
+		logs.Info("iperf3 %s fairness jain index %.3f spread %.2f", fairness.Direction, fairness.JainIndex, fairness.Spread)
+		if fairness.Starved > 0 {
+			logs.Warning("iperf3 %s stream starved in %d of %d intervals", fairness.Direction, fairness.Starved, len(fairness.Intervals))
+		}
 	}
 
-	if configCache.ServerJsonFormat {
-		fmt.Fprintf(&builder, " --json %d", configCache.ServerInterval)
+	if summary.RetransmitHeavy {
+		logs.Warning("iperf3 retransmit heavy run, %d retransmits (%.2f%% of segments)",
+			summary.Retransmits, summary.RetransmitPercent)
 	}
 
-	fmt.Fprintf(&builder, " --forceflush")
+	return summary, nil
+}
 
-	stdout, stdErr, cancel, exitCodeChan, err := ExecuteAsync(filepath.Join(ToolDirGet(), "iperf3.exe"), strings.Fields(builder.String()))
+func LoadResult(filePath string) (*Result, error) {
+	text, err := os.ReadFile(filePath)
 	if err != nil {
-		logs.Warning("iperf server startup failed, %s", err.Error())
 		return nil, err
 	}
 
-	srv := new(IperfServer)
-	srv.stdOut = stdout.Name()
-	srv.stdErr = stdErr.Name()
-	srv.cancel = cancel
-	srv.running = true
-
-	go func() {
-		exitCode := <-exitCodeChan
+	if isJSONStream(text) {
+		text, err = JSONStreamAssemble(text)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-		logs.Info("iperf3.exe index: %d exit code %d", index, exitCode)
+	result := new(Result)
+	if err := json.Unmarshal(text, result); err != nil {
+		return nil, err
+	}
 
-		ReadResult(stdout.Name(), configCache.ServerLog)
-		ReadResult(stdErr.Name(), configCache.ServerLog)
+	result.LabelDirections()
 
-		srv.exitCode = exitCode
-		srv.running = false
-	}()
-
-	return srv, nil
+	return result, nil
 }
 
-func ClientStartup(cnt int) (*IperfServer, error) {
-
-	value, err := json.Marshal(configCache)
+func ServerStartup(engine Engine, config Config, index int) (*IperfServer, error) {
+	value, err := json.Marshal(config.Redacted())
 	if err != nil {
 		logs.Error("json marshal config fail, %s", err.Error())
 	} else {
-		logs.Info("iperf client run times %d with options %s", cnt, string(value))
+		logs.Info("iperf server options %s", string(value))
 	}
 
-	builder := strings.Builder{}
+	handle, err := engine.Run(NewServerSpec(config, index))
+	if err != nil {
+		logs.Warning("iperf server startup failed, %s", err.Error())
+		return nil, err
+	}
 
-	fmt.Fprintf(&builder, " -B %s", configCache.ClientListen)
-	fmt.Fprintf(&builder, " -c %s", configCache.ClientAddress)
-	fmt.Fprintf(&builder, " -p %d", configCache.ClientPort)
-	fmt.Fprintf(&builder, " -t %d", configCache.ClientRunTime)
-	fmt.Fprintf(&builder, " -P %d", configCache.ClientStreams)
-	fmt.Fprintf(&builder, " --interval 1")
+	srv := newIperfServer(handle)
 
-	if configCache.ClientOmitSec > 0 {
-		fmt.Fprintf(&builder, " -O %d", configCache.ClientOmitSec)
-	}
+	go func() {
+		defer close(srv.done)
 
-	if configCache.ClientBandwidth > 0 {
-		fmt.Fprintf(&builder, " -b %d%c", configCache.ClientBandwidth, configCache.ClientBandwidthUnit[0])
-	}
+		for summary := range handle.Results() {
+			logs.Info("iperf3 server index: %d test from %s done", index, summary.Target)
+			runErr := runError(summary, "", 0)
+			metricsRecord(summary.Target, summary.Protocol, summary, runErr)
+			influxRecord(summary)
+			historyRecord(NewHistoryEntry(RoleServer, config, summary, runErr))
+		}
 
-	if configCache.ClientWindows > 0 {
-		fmt.Fprintf(&builder, " -w %d%c", configCache.ClientWindows, configCache.ClientWindowsUnit[0])
-	}
+		summary, runErr := handle.Wait()
 
-	if configCache.ClientProtocol == "udp" {
-		builder.WriteString(" -u")
-	}
+		logs.Info("iperf3 %s server index: %d exit code %d", engine.Name(), index, handle.ExitCode())
 
-	if configCache.ClientNoDelay {
-		builder.WriteString(" -N")
-	}
+		srv.err = runErr
+		srv.summary = summary
+	}()
 
-	if configCache.ClientZeroCopy {
-		builder.WriteString(" -Z")
-	}
+	return srv, nil
+}
 
-	if configCache.ClientReverseMode {
-		builder.WriteString(" -R")
-	}
+func ClientStartup(engine Engine, config Config, cnt int) (*IperfServer, error) {
 
-	if configCache.ClientBidirectionalMode {
-		builder.WriteString(" --bidir")
+	value, err := json.Marshal(config.Redacted())
+	if err != nil {
+		logs.Error("json marshal config fail, %s", err.Error())
+	} else {
+		logs.Info("iperf client run times %d with options %s", cnt, string(value))
 	}
 
-	if configCache.ClientPayload > 0 {
-		fmt.Fprintf(&builder, " -l %d", configCache.ClientPayload)
+	cpuPeakReset()
+	handle, err := engine.Run(NewClientSpec(config))
+	if err != nil {
+		logs.Warning("iperf client startup failed, %s", err.Error())
+		return nil, err
 	}
 
-	if configCache.ClientJsonFormat {
-		builder.WriteString(" -J")
-	}
+	srv := newIperfServer(handle)
+	watchdog := NewWatchdog(clientDeadline(config), handle)
 
-	if configCache.ClientDscpValue > 0 {
-		fmt.Fprintf(&builder, " --dscp %d", configCache.ClientDscpValue)
-	}
+	go func() {
+		defer close(srv.done)
 
-	if configCache.ClientTypeService > 0 {
-		fmt.Fprintf(&builder, " --tos %d", configCache.ClientTypeService)
-	}
+		for event := range handle.Events() {
+			logs.Info("iperf3 interval %d %s %s", event.Index, event.Target, BitRateView(event.BitsPerSecond()))
+			intervalPublish(event)
+		}
 
-	if configCache.ClientDontFragment {
-		builder.WriteString(" --dont-fragment")
-	}
+		summary, runErr := handle.Wait()
+		watchdog.Stop()
 
-	if configCache.ClientMaxmumSegment {
-		builder.WriteString(" --set-mss")
-	}
+		logs.Info("iperf3 %s client exit code %d", engine.Name(), handle.ExitCode())
 
-	if configCache.ClientOnlyIPv4 {
-		builder.WriteString(" --version4")
-	}
+		runErr = watchdog.apply(summary, runErr, handle.ExitCode())
 
-	if configCache.ClientOnlyIPv6 {
-		builder.WriteString(" --version6")
-	}
+		if runErr != nil {
+			logs.Warning("iperf client run failed, %s", runErr.Error())
+		}
 
-	builder.WriteString(" --get-server-output")
+		clientRunComplete(config, summary, runErr)
 
-	stdout, stdErr, cancel, exitCodeChan, err := ExecuteAsync(filepath.Join(ToolDirGet(), "iperf3.exe"), strings.Fields(builder.String()))
-	if err != nil {
-		logs.Warning("iperf client startup failed, %s", err.Error())
-		return nil, err
-	}
+		srv.summary = summary
+		srv.err = runErr
+	}()
 
-	srv := new(IperfServer)
-	srv.stdOut = stdout.Name()
-	srv.stdErr = stdErr.Name()
-	srv.cancel = cancel
-	srv.running = true
+	return srv, nil
+}
 
-	go func() {
-		exitCode := <-exitCodeChan
+func clientRunComplete(config Config, summary *RunSummary, runErr *IperfError) {
+	baselineEvaluate(config, summary)
+	thresholdEvaluate(config, summary)
+	anomalyEvaluate(config, summary)
+	cpuBoundEvaluate(config, summary)
 
-		ReadResult(stdout.Name(), configCache.ClientLog)
-		ReadResult(stdErr.Name(), configCache.ClientLog)
+	if summary != nil && config.ClientCsvExport && summary.File != "" {
+		err := ExportCSV([]*Result{summary.Result()}, csvFilePath(summary.File))
+		if err != nil {
+			logs.Warning("iperf client export csv fail, %s", err.Error())
+		}
+	}
 
-		logs.Info("iperf3.exe client exit code %d", exitCode)
+	if summary != nil && config.ClientHtmlReport && summary.File != "" {
+		err := ExportHTMLReport([]*RunSummary{summary}, htmlFilePath(summary.File))
+		if err != nil {
+			logs.Warning("iperf client export html report fail, %s", err.Error())
+		}
+	}
 
-		srv.exitCode = exitCode
-		srv.running = false
-	}()
+	metricsRecord(fmt.Sprintf("%s:%d", config.ClientAddress, config.ClientPort), config.ClientProtocol, summary, runErr)
+	influxRecord(summary)
 
-	return srv, nil
+	historyRecord(NewHistoryEntry(RoleClient, config, summary, runErr))
 }
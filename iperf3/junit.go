@@ -0,0 +1,182 @@
+package iperf3
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+)
+
+type RunRecord struct {
+	Index   int
+	Summary *RunSummary
+	Err     *IperfError
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitTestCase struct {
+	Name       string          `xml:"name,attr"`
+	ClassName  string          `xml:"classname,attr"`
+	Time       float64         `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Failure    *junitFailure   `xml:"failure,omitempty"`
+}
+
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      float64         `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Hostname  string          `xml:"hostname,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+func junitProperties(summary *RunSummary) []junitProperty {
+	properties := []junitProperty{
+		{"target", summary.Target},
+		{"protocol", summary.Protocol},
+		{"streams", fmt.Sprint(summary.Streams)},
+		{"sent_bits_per_second", fmt.Sprintf("%.0f", summary.SentBitsPerSecond)},
+		{"received_bits_per_second", fmt.Sprintf("%.0f", summary.ReceivedBitsPerSecond)},
+		{"host_cpu", fmt.Sprintf("%.2f", summary.HostCpu)},
+		{"remote_cpu", fmt.Sprintf("%.2f", summary.RemoteCpu)},
+	}
+	if summary.IsUDP() {
+		properties = append(properties,
+			junitProperty{"jitter_ms", fmt.Sprintf("%.3f", summary.JitterMs)},
+			junitProperty{"lost_percent", fmt.Sprintf("%.3f", summary.LostPercent)})
+	} else {
+		properties = append(properties, junitProperty{"retransmits", fmt.Sprint(summary.Retransmits)})
+	}
+	if summary.Baseline != nil {
+		properties = append(properties,
+			junitProperty{"baseline", summary.Baseline.Verdict},
+			junitProperty{"baseline_throughput_delta_percent", fmt.Sprintf("%.2f", summary.Baseline.ThroughputDelta)})
+	}
+	return properties
+}
+
+func junitCase(record RunRecord, className string) junitTestCase {
+	testCase := junitTestCase{
+		Name:      fmt.Sprintf("run %d", record.Index),
+		ClassName: className,
+	}
+
+	summary := record.Summary
+	if summary != nil {
+		testCase.Time = summary.Duration
+		testCase.Properties = junitProperties(summary)
+	}
+
+	if record.Err != nil {
+		testCase.Failure = &junitFailure{
+			Message: record.Err.Message,
+			Type:    "iperf3:" + string(record.Err.Kind),
+			Text:    record.Err.Error(),
+		}
+		return testCase
+	}
+
+	if summary == nil {
+		testCase.Failure = &junitFailure{Message: "run produced no result", Type: "iperf3"}
+		return testCase
+	}
+
+	if summary.Failed() {
+		testCase.Failure = &junitFailure{
+			Message: summary.Error,
+			Type:    "iperf3:" + string(summary.ErrorKind),
+			Text:    summary.Error,
+		}
+		return testCase
+	}
+
+	if summary.Sla != nil && !summary.Sla.Pass {
+		messages := make([]string, 0)
+		for _, violation := range summary.Sla.Violations {
+			messages = append(messages, violation.Message)
+		}
+		testCase.Failure = &junitFailure{
+			Message: strings.Join(messages, "; "),
+			Type:    "threshold",
+			Text:    strings.Join(messages, "\n"),
+		}
+	}
+
+	return testCase
+}
+
+func WriteJUnit(w io.Writer, config Config, records []RunRecord) error {
+	hostname, _ := os.Hostname()
+	className := fmt.Sprintf("iperf3.%s.%s", strings.ReplaceAll(BaselineTarget(config), ".", "_"), config.ClientProtocol)
+
+	suite := junitTestSuite{
+		Name:      "iperf3 " + BaselineTarget(config),
+		Timestamp: time.Now().Format(time.RFC3339),
+		Hostname:  hostname,
+		TestCases: make([]junitTestCase, 0, len(records)),
+	}
+
+	for _, record := range records {
+		testCase := junitCase(record, className)
+		if testCase.Failure != nil {
+			suite.Failures++
+		}
+		suite.Time += testCase.Time
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	suite.Tests = len(suite.TestCases)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return encoder.Encode(suite)
+}
+
+func ExportJUnit(config Config, records []RunRecord, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	err = WriteJUnit(file, config, records)
+	if err != nil {
+		return err
+	}
+
+	logs.Info("export junit report %s with %d runs", path, len(records))
+	return nil
+}
+
+func clientJUnitReport(config Config, records []RunRecord, path string) {
+	if path == "" {
+		if !config.ClientJUnitReport || config.ClientLog == "" {
+			return
+		}
+		path = filepath.Join(config.ClientLog, fmt.Sprintf("iperf3_junit_%s.xml", GetTimestamp()))
+	}
+	if err := ExportJUnit(config, records, path); err != nil {
+		logs.Warning("iperf3 junit report fail, %s", err.Error())
+	}
+}
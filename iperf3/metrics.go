@@ -0,0 +1,205 @@
+package iperf3
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+)
+
+type metricsKey struct {
+	target   string
+	protocol string
+}
+
+type metricsRun struct {
+	time    time.Time
+	summary *RunSummary
+}
+
+type metricsOutcomeKey struct {
+	metricsKey
+	outcome string
+}
+
+var metricsLock sync.Mutex
+var metricsLastRun = make(map[metricsKey]*metricsRun)
+var metricsRuns = make(map[metricsOutcomeKey]int64)
+
+func metricsLabel(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
+
+func metricsLabels(labels ...string) string {
+	items := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		items = append(items, fmt.Sprintf(`%s="%s"`, labels[i], metricsLabel(labels[i+1])))
+	}
+	return "{" + strings.Join(items, ",") + "}"
+}
+
+func metricsHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+func metricsRecord(target, protocol string, summary *RunSummary, runErr *IperfError) {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+
+	key := metricsKey{target: target, protocol: protocol}
+
+	outcome := HistoryStatusOK
+	if runErr != nil {
+		outcome = string(runErr.Kind)
+	}
+	metricsRuns[metricsOutcomeKey{key, outcome}]++
+
+	if summary != nil && !summary.Failed() {
+		metricsLastRun[key] = &metricsRun{time: time.Now(), summary: summary}
+	}
+}
+
+func metricsSortedKeys() []metricsKey {
+	keys := make([]metricsKey, 0, len(metricsLastRun))
+	for key := range metricsLastRun {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].target != keys[j].target {
+			return keys[i].target < keys[j].target
+		}
+		return keys[i].protocol < keys[j].protocol
+	})
+	return keys
+}
+
+func writeRunMetrics(w io.Writer) {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+
+	keys := metricsSortedKeys()
+
+	metricsHeader(w, "iperf3_last_run_throughput_bits_per_second", "gauge", "Receiver side throughput of the last successful run.")
+	for _, key := range keys {
+		summary := metricsLastRun[key].summary
+		for _, direction := range []*DirectionSummary{summary.Upload, summary.Download} {
+			if direction == nil {
+				continue
+			}
+			fmt.Fprintf(w, "iperf3_last_run_throughput_bits_per_second%s %g\n",
+				metricsLabels("target", key.target, "protocol", key.protocol, "direction", direction.Direction),
+				direction.ReceivedBitsPerSecond)
+		}
+	}
+
+	metricsHeader(w, "iperf3_last_run_retransmits", "gauge", "TCP retransmits of the last successful run.")
+	for _, key := range keys {
+		fmt.Fprintf(w, "iperf3_last_run_retransmits%s %d\n",
+			metricsLabels("target", key.target, "protocol", key.protocol), metricsLastRun[key].summary.Retransmits)
+	}
+
+	metricsHeader(w, "iperf3_last_run_jitter_ms", "gauge", "UDP jitter in milliseconds of the last successful run.")
+	for _, key := range keys {
+		fmt.Fprintf(w, "iperf3_last_run_jitter_ms%s %g\n",
+			metricsLabels("target", key.target, "protocol", key.protocol), metricsLastRun[key].summary.JitterMs)
+	}
+
+	metricsHeader(w, "iperf3_last_run_lost_percent", "gauge", "UDP packet loss percent of the last successful run.")
+	for _, key := range keys {
+		fmt.Fprintf(w, "iperf3_last_run_lost_percent%s %g\n",
+			metricsLabels("target", key.target, "protocol", key.protocol), metricsLastRun[key].summary.LostPercent)
+	}
+
+	metricsHeader(w, "iperf3_last_run_timestamp_seconds", "gauge", "Unix time of the last successful run.")
+	for _, key := range keys {
+		fmt.Fprintf(w, "iperf3_last_run_timestamp_seconds%s %d\n",
+			metricsLabels("target", key.target, "protocol", key.protocol), metricsLastRun[key].time.Unix())
+	}
+
+	outcomes := make([]metricsOutcomeKey, 0, len(metricsRuns))
+	for key := range metricsRuns {
+		outcomes = append(outcomes, key)
+	}
+	sort.Slice(outcomes, func(i, j int) bool {
+		return fmt.Sprint(outcomes[i]) < fmt.Sprint(outcomes[j])
+	})
+
+	metricsHeader(w, "iperf3_runs_total", "counter", "Number of client runs by outcome.")
+	for _, key := range outcomes {
+		fmt.Fprintf(w, "iperf3_runs_total%s %d\n",
+			metricsLabels("target", key.target, "protocol", key.protocol, "outcome", key.outcome), metricsRuns[key])
+	}
+}
+
+func writeServerMetrics(w io.Writer) {
+	serverMutex.Lock()
+	defer serverMutex.Unlock()
+
+	metricsHeader(w, "iperf3_server_pool_running", "gauge", "Whether the iperf3 server pool is started.")
+	running := 0
+	if ServerRunning() {
+		running = 1
+	}
+	fmt.Fprintf(w, "iperf3_server_pool_running %d\n", running)
+
+	slots := make([]ServerSlot, 0)
+	if serverSupervisor != nil {
+		slots = serverSupervisor.Slots()
+	}
+
+	metricsHeader(w, "iperf3_server_running", "gauge", "Whether the iperf3 server instance on the port is running.")
+	for _, slot := range slots {
+		up := 0
+		if slot.State == ServerStateUp {
+			up = 1
+		}
+		fmt.Fprintf(w, "iperf3_server_running%s %d\n", metricsLabels("target", slot.Target, "protocol", "all"), up)
+	}
+
+	metricsHeader(w, "iperf3_server_restarts_total", "counter", "Restarts of the iperf3 server instance on the port.")
+	for _, slot := range slots {
+		fmt.Fprintf(w, "iperf3_server_restarts_total%s %d\n", metricsLabels("target", slot.Target, "protocol", "all"), slot.Restarts)
+	}
+}
+
+func writeHostMetrics(w io.Writer) {
+	cpuPercent, memPercent := cpuUsageLast()
+
+	metricsHeader(w, "iperf3_host_cpu_percent", "gauge", "Host CPU usage percent.")
+	fmt.Fprintf(w, "iperf3_host_cpu_percent %g\n", cpuPercent)
+
+	metricsHeader(w, "iperf3_host_memory_percent", "gauge", "Host memory usage percent.")
+	fmt.Fprintf(w, "iperf3_host_memory_percent %g\n", memPercent)
+}
+
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writeRunMetrics(w)
+	writeServerMetrics(w)
+	writeHostMetrics(w)
+}
+
+func MetricsInit() {
+	if configCache.MetricsListen == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", metricsHandler)
+
+	go func() {
+		logs.Info("metrics listen on %s", configCache.MetricsListen)
+		err := http.ListenAndServe(configCache.MetricsListen, mux)
+		if err != nil {
+			logs.Error("metrics listen fail, %s", err.Error())
+		}
+	}()
+}
@@ -0,0 +1,271 @@
+package iperf3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+)
+
+const (
+	ProcessStdoutLimit       = 128 << 20
+	ProcessServerStdoutLimit = 1 << 20
+	ProcessStderrLimit       = 1 << 20
+	ProcessStopGrace         = 5 * time.Second
+)
+
+const (
+	ctrlCEvent       = 0
+	createNewConsole = 0x00000010
+	consoleCtrlDelay = 100 * time.Millisecond
+)
+
+var (
+	kernel32                     = syscall.NewLazyDLL("kernel32.dll")
+	procAttachConsole            = kernel32.NewProc("AttachConsole")
+	procFreeConsole              = kernel32.NewProc("FreeConsole")
+	procSetConsoleCtrlHandler    = kernel32.NewProc("SetConsoleCtrlHandler")
+	procGenerateConsoleCtrlEvent = kernel32.NewProc("GenerateConsoleCtrlEvent")
+)
+
+var consoleMutex sync.Mutex
+
+type boundedBuffer struct {
+	mutex   sync.Mutex
+	data    []byte
+	limit   int
+	tail    bool
+	dropped int64
+}
+
+func (b *boundedBuffer) Write(body []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.tail {
+		b.data = append(b.data, body...)
+		if len(b.data) > 2*b.limit {
+			drop := len(b.data) - b.limit
+			b.data = append(b.data[:0], b.data[drop:]...)
+			b.dropped += int64(drop)
+		}
+		return len(body), nil
+	}
+
+	room := b.limit - len(b.data)
+	if room > len(body) {
+		room = len(body)
+	}
+	b.data = append(b.data, body[:room]...)
+	b.dropped += int64(len(body) - room)
+
+	return len(body), nil
+}
+
+func (b *boundedBuffer) Bytes() []byte {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.tail && len(b.data) > b.limit {
+		return append([]byte{}, b.data[len(b.data)-b.limit:]...)
+	}
+	return append([]byte{}, b.data...)
+}
+
+func (b *boundedBuffer) Dropped() int64 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.tail && len(b.data) > b.limit {
+		return b.dropped + int64(len(b.data)-b.limit)
+	}
+	return b.dropped
+}
+
+type ProcessOutput struct {
+	Limit int
+	Tail  bool
+	Line  func(string)
+}
+
+type Process struct {
+	binary   string
+	pid      int
+	cancel   context.CancelFunc
+	stdout   *boundedBuffer
+	stderr   *boundedBuffer
+	lines    *lineWriter
+	done     chan struct{}
+	exitCode int
+}
+
+func StartProcess(binary string, args []string, output ProcessOutput) (*Process, error) {
+	logs.Info("StartProcess %s %v", binary, args)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	exe := exec.CommandContext(ctx, binary, args...)
+	exe.SysProcAttr = &syscall.SysProcAttr{
+		HideWindow:    true,
+		CreationFlags: createNewConsole,
+	}
+
+	proc := &Process{
+		binary: binary,
+		cancel: cancel,
+		stdout: &boundedBuffer{limit: output.Limit, tail: output.Tail},
+		stderr: &boundedBuffer{limit: ProcessStderrLimit},
+		done:   make(chan struct{}),
+	}
+
+	exe.Stdout = proc.stdout
+	if output.Line != nil {
+		proc.lines = newLineWriter(output.Line)
+		exe.Stdout = io.MultiWriter(proc.stdout, proc.lines)
+	}
+	exe.Stderr = proc.stderr
+
+	err := exe.Start()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	proc.pid = exe.Process.Pid
+
+	go func() {
+		defer close(proc.done)
+		defer cancel()
+
+		err := exe.Wait()
+		if _, ok := err.(*exec.ExitError); err != nil && !ok {
+			logs.Warning("%s wait fail, %s", binary, err.Error())
+		}
+		if proc.lines != nil {
+			proc.lines.Flush()
+		}
+
+		if dropped := proc.stdout.Dropped(); dropped > 0 && !output.Tail {
+			logs.Warning("%s stdout over %d bytes, %d bytes dropped", binary, output.Limit, dropped)
+		}
+		if dropped := proc.stderr.Dropped(); dropped > 0 {
+			logs.Warning("%s stderr over %d bytes, %d bytes dropped", binary, ProcessStderrLimit, dropped)
+		}
+
+		proc.exitCode = exe.ProcessState.ExitCode()
+
+		logs.Info("%s exit code %d", binary, proc.exitCode)
+	}()
+
+	return proc, nil
+}
+
+var iperfVersionRegexp = regexp.MustCompile(`iperf (\d+)\.(\d+)`)
+
+func parseIperfVersion(text string) (int, int, bool) {
+	match := iperfVersionRegexp.FindStringSubmatch(text)
+	if match == nil {
+		return 0, 0, false
+	}
+	major, _ := strconv.Atoi(match[1])
+	minor, _ := strconv.Atoi(match[2])
+	return major, minor, true
+}
+
+func ProbeVersion(binary string) (int, int, error) {
+	exe := exec.Command(binary, "--version")
+	exe.SysProcAttr = &syscall.SysProcAttr{
+		HideWindow: true,
+	}
+	output, err := exe.Output()
+	if err != nil {
+		return 0, 0, err
+	}
+	major, minor, ok := parseIperfVersion(string(output))
+	if !ok {
+		return 0, 0, fmt.Errorf("unknown iperf3 version %q", strings.TrimSpace(string(output)))
+	}
+	return major, minor, nil
+}
+
+func (p *Process) Done() <-chan struct{} {
+	return p.done
+}
+
+func (p *Process) Wait() int {
+	<-p.done
+	return p.exitCode
+}
+
+func (p *Process) ExitCode() int {
+	select {
+	case <-p.done:
+		return p.exitCode
+	default:
+		return -1
+	}
+}
+
+func (p *Process) Stdout() []byte {
+	return p.stdout.Bytes()
+}
+
+func (p *Process) Stderr() string {
+	return string(p.stderr.Bytes())
+}
+
+func (p *Process) Kill() {
+	p.cancel()
+}
+
+func (p *Process) Interrupt() error {
+	consoleMutex.Lock()
+	defer consoleMutex.Unlock()
+
+	r, _, err := procAttachConsole.Call(uintptr(p.pid))
+	if r == 0 {
+		return fmt.Errorf("attach console of pid %d, %s", p.pid, err.Error())
+	}
+	defer procFreeConsole.Call()
+
+	procSetConsoleCtrlHandler.Call(0, 1)
+	defer procSetConsoleCtrlHandler.Call(0, 0)
+
+	r, _, err = procGenerateConsoleCtrlEvent.Call(ctrlCEvent, 0)
+	if r == 0 {
+		return fmt.Errorf("ctrl-c to pid %d, %s", p.pid, err.Error())
+	}
+
+	time.Sleep(consoleCtrlDelay)
+
+	return nil
+}
+
+func (p *Process) Stop(grace time.Duration) {
+	select {
+	case <-p.done:
+		return
+	default:
+	}
+
+	err := p.Interrupt()
+	if err != nil {
+		logs.Warning("%s interrupt fail, %s", p.binary, err.Error())
+		p.Kill()
+		return
+	}
+
+	select {
+	case <-p.done:
+		logs.Info("%s pid %d stopped", p.binary, p.pid)
+	case <-time.After(grace):
+		logs.Warning("%s pid %d not stopped after %s, kill", p.binary, p.pid, grace)
+		p.Kill()
+	}
+}
@@ -0,0 +1,315 @@
+package iperf3
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/astaxie/beego/logs"
+)
+
+const (
+	chartWidth   = 800
+	chartHeight  = 260
+	chartMargin  = 60
+	chartPadding = 20
+)
+
+var chartColors = []string{
+	"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd",
+	"#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf",
+}
+
+type chartSeries struct {
+	name   string
+	color  string
+	width  float64
+	points [][2]float64
+}
+
+type reportRun struct {
+	Index   int
+	Result  *Result
+	Summary *RunSummary
+	Chart   template.HTML
+}
+
+type reportPage struct {
+	Title   string
+	Version string
+	Created string
+	Runs    []reportRun
+}
+
+func chartSeriesList(result *Result) []chartSeries {
+	streams := make(map[string]*chartSeries)
+	totals := make(map[string]*chartSeries)
+
+	for i := range result.Intervals {
+		interval := &result.Intervals[i]
+		for _, stream := range interval.Streams {
+			name := fmt.Sprintf("stream %d %s", stream.Socket, stream.Direction)
+			series, ok := streams[name]
+			if !ok {
+				series = &chartSeries{name: name, width: 1.5}
+				streams[name] = series
+			}
+			series.points = append(series.points, [2]float64{stream.End, stream.BitPerSecond})
+		}
+		for _, direction := range []string{DirectionUpload, DirectionDownload} {
+			sum, ok := result.IntervalSum(interval, direction)
+			if !ok {
+				continue
+			}
+			series, ok := totals[direction]
+			if !ok {
+				series = &chartSeries{name: "total " + direction, width: 3}
+				totals[direction] = series
+			}
+			series.points = append(series.points, [2]float64{sum.End, sum.BitPerSecond})
+		}
+	}
+
+	output := make([]chartSeries, 0)
+	for _, group := range []map[string]*chartSeries{totals, streams} {
+		names := make([]string, 0)
+		for name := range group {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			series := group[name]
+			series.color = chartColors[len(output)%len(chartColors)]
+			output = append(output, *series)
+		}
+	}
+	return output
+}
+
+func ThroughputChart(result *Result) template.HTML {
+	series := chartSeriesList(result)
+
+	var maxX, maxY float64
+	for _, s := range series {
+		for _, point := range s.points {
+			if point[0] > maxX {
+				maxX = point[0]
+			}
+			if point[1] > maxY {
+				maxY = point[1]
+			}
+		}
+	}
+	if maxX == 0 {
+		maxX = 1
+	}
+	if maxY == 0 {
+		maxY = 1
+	}
+
+	plotW := float64(chartWidth - chartMargin - chartPadding)
+	plotH := float64(chartHeight - 2*chartPadding)
+
+	x := func(value float64) float64 { return chartMargin + value/maxX*plotW }
+	y := func(value float64) float64 { return chartPadding + plotH - value/maxY*plotH }
+
+	builder := strings.Builder{}
+	fmt.Fprintf(&builder, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		chartWidth, chartHeight+20*((len(series)+3)/4), chartWidth, chartHeight+20*((len(series)+3)/4))
+
+	for i := 0; i <= 4; i++ {
+		value := maxY * float64(i) / 4
+		fmt.Fprintf(&builder, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#ddd"/>`,
+			chartMargin, y(value), chartWidth-chartPadding, y(value))
+		fmt.Fprintf(&builder, `<text x="%d" y="%.1f" font-size="10" text-anchor="end">%s</text>`,
+			chartMargin-4, y(value)+3, template.HTMLEscapeString(BitRateView(value)))
+	}
+	for i := 0; i <= 5; i++ {
+		value := maxX * float64(i) / 5
+		fmt.Fprintf(&builder, `<text x="%.1f" y="%d" font-size="10" text-anchor="middle">%.1fs</text>`,
+			x(value), chartHeight-4, value)
+	}
+
+	for _, s := range series {
+		points := make([]string, 0, len(s.points))
+		for _, point := range s.points {
+			points = append(points, fmt.Sprintf("%.1f,%.1f", x(point[0]), y(point[1])))
+		}
+		fmt.Fprintf(&builder, `<polyline fill="none" stroke="%s" stroke-width="%.1f" points="%s"/>`,
+			s.color, s.width, strings.Join(points, " "))
+	}
+
+	for i, s := range series {
+		lx := chartMargin + (i%4)*180
+		ly := chartHeight + 14 + (i/4)*20
+		fmt.Fprintf(&builder, `<rect x="%d" y="%d" width="12" height="4" fill="%s"/>`, lx, ly-4, s.color)
+		fmt.Fprintf(&builder, `<text x="%d" y="%d" font-size="11">%s</text>`, lx+16, ly, template.HTMLEscapeString(s.name))
+	}
+
+	builder.WriteString(`</svg>`)
+	return template.HTML(builder.String())
+}
+
+var reportFuncs = template.FuncMap{
+	"rate": BitRateView,
+	"bytes": func(size int64) string {
+		return ByteView(size)
+	},
+	"percent": func(value float64) float64 {
+		return value * 100
+	},
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(reportFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: Segoe UI, Arial, sans-serif; margin: 24px; color: #222; }
+table { border-collapse: collapse; margin: 8px 0 16px 0; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; text-align: right; }
+th { background: #f2f2f2; }
+td.text, th.text { text-align: left; }
+h2 { border-bottom: 1px solid #ccc; padding-bottom: 4px; }
+.error { color: #c00; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Created {{.Created}} by iperf-windows {{.Version}}</p>
+
+<h2>Summary</h2>
+<table>
+<tr><th>Run</th><th class="text">Target</th><th class="text">Protocol</th><th>Duration</th><th>Sent</th><th>Received</th><th>Retransmits</th><th>Jitter</th><th>Loss</th><th>Host CPU</th><th>Remote CPU</th><th class="text">SLA</th><th class="text">Baseline</th><th class="text">Error</th></tr>
+{{range .Runs}}<tr><td>{{.Index}}</td><td class="text">{{.Summary.Target}}</td><td class="text">{{.Summary.Protocol}}</td><td>{{printf "%.1f" .Summary.Duration}}s</td><td>{{rate .Summary.SentBitsPerSecond}}</td><td>{{rate .Summary.ReceivedBitsPerSecond}}</td><td>{{.Summary.Retransmits}}</td><td>{{printf "%.3f" .Summary.JitterMs}} ms</td><td>{{printf "%.2f" .Summary.LostPercent}}%</td><td>{{printf "%.1f" .Summary.HostCpu}}%</td><td>{{printf "%.1f" .Summary.RemoteCpu}}%</td><td class="text">{{with .Summary.Sla}}{{if .Pass}}pass{{else}}<span class="error">fail</span>{{end}}{{end}}</td><td class="text">{{with .Summary.Baseline}}{{.Verdict}} {{printf "%+.1f" .ThroughputDelta}}%{{end}}</td><td class="text error">{{.Summary.Error}}</td></tr>
+{{end}}</table>
+
+{{range .Runs}}
+<h2>Run {{.Index}} {{.Summary.Target}}{{if .Summary.Interrupted}} <span class="error">(interrupted)</span>{{end}}</h2>
+{{with .Result.Start}}
+<h3>Test Configuration</h3>
+<table>
+<tr><th class="text">Version</th><td class="text">{{.Version}}</td></tr>
+<tr><th class="text">System</th><td class="text">{{.SystemInfo}}</td></tr>
+<tr><th class="text">Time</th><td class="text">{{.Timestamp.Time}}</td></tr>
+<tr><th class="text">Protocol</th><td class="text">{{.TestStart.Protocol}}</td></tr>
+<tr><th class="text">Streams</th><td class="text">{{.TestStart.NumStreams}}</td></tr>
+<tr><th class="text">Block Size</th><td class="text">{{bytes .TestStart.BlkSize}}</td></tr>
+<tr><th class="text">Duration</th><td class="text">{{.TestStart.Duration}}s (omit {{.TestStart.Omit}}s)</td></tr>
+<tr><th class="text">Reverse</th><td class="text">{{.TestStart.Reverse}}</td></tr>
+<tr><th class="text">Bidirectional</th><td class="text">{{.TestStart.Bidir}}</td></tr>
+<tr><th class="text">TCP MSS</th><td class="text">{{.TcpMssDefault}}</td></tr>
+</table>
+{{end}}
+{{with .Summary.Sla}}{{if not .Pass}}
+<h3>Threshold Violations</h3>
+<ul>
+{{range .Violations}}<li class="error">{{.Rule}}: {{.Message}}</li>
+{{end}}</ul>
+{{end}}{{end}}
+{{with .Summary.Baseline}}{{if .Reasons}}
+<h3>Baseline Regressions</h3>
+<ul>
+{{range .Reasons}}<li class="error">{{.}}</li>
+{{end}}</ul>
+{{end}}{{end}}
+{{with .Summary.ServerViewRows}}
+<h3>Client and Server View</h3>
+<table>
+<tr><th class="text">Direction</th><th class="text">Metric</th><th>Client</th><th>Server</th><th>Delta</th></tr>
+{{range .}}<tr><td class="text">{{.Direction}}</td><td class="text">{{.Metric}}</td><td>{{.Client}}</td><td>{{.Server}}</td><td{{if .Disagree}} class="error"{{end}}>{{printf "%+.2f" .Delta}}%</td></tr>
+{{end}}</table>
+{{end}}
+{{with .Summary.Disagreements}}
+<h3>Client and Server Disagreements</h3>
+<ul>
+{{range .}}<li class="error">{{.}}</li>
+{{end}}</ul>
+{{end}}
+<h3>Throughput</h3>
+{{.Chart}}
+{{with .Summary.Anomalies}}
+<h3>Anomalies</h3>
+<table>
+<tr><th class="text">Kind</th><th class="text">Direction</th><th>Interval</th><th class="text">Time</th><th>Throughput</th><th>Median</th><th>Retransmits</th></tr>
+{{range .}}<tr><td class="text error">{{.Kind}}</td><td class="text">{{.Direction}}</td><td>{{printf "%.1f" .Start}}-{{printf "%.1f" .End}}s</td><td class="text">{{.Time}}</td><td>{{rate .BitsPerSecond}}</td><td>{{rate .Median}}</td><td>{{.Retransmits}}</td></tr>
+{{end}}</table>
+{{end}}
+{{range .Summary.Fairness}}
+<h3>Stream Fairness {{.Direction}}</h3>
+<p>Jain index {{printf "%.3f" .JainIndex}}, min {{rate .Min}}, max {{rate .Max}}, spread {{printf "%.2f" .Spread}}, starved intervals {{.Starved}}/{{len .Intervals}}</p>
+<table>
+<tr><th>Stream</th><th>Throughput</th><th>Share</th></tr>
+{{range .Shares}}<tr><td>{{.Socket}}</td><td>{{rate .BitsPerSecond}}</td><td>{{printf "%.1f" (percent .Share)}}%</td></tr>
+{{end}}</table>
+<table>
+<tr><th>Interval</th><th>Jain Index</th><th>Spread</th><th class="text">Starved Streams</th></tr>
+{{range .Intervals}}<tr><td>{{printf "%.1f" .Start}}-{{printf "%.1f" .End}}s</td><td>{{printf "%.3f" .JainIndex}}</td><td>{{printf "%.2f" .Spread}}</td><td class="text{{if .Starved}} error{{end}}">{{range .Starved}}{{.}} {{end}}</td></tr>
+{{end}}</table>
+{{end}}
+{{with .Summary.CpuBound}}
+<h3>CPU Bound</h3>
+<ul>
+{{range .Findings}}<li class="error">{{.Message}}</li>
+{{end}}</ul>
+<ul>
+{{range .Remedies}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}
+{{with .Result.End.CpuPercent}}
+<h3>CPU Utilisation</h3>
+<table>
+<tr><th></th><th>Total</th><th>User</th><th>System</th></tr>
+<tr><th class="text">Host</th><td>{{printf "%.2f" .HostTotal}}%</td><td>{{printf "%.2f" .HostUser}}%</td><td>{{printf "%.2f" .HostSystem}}%</td></tr>
+<tr><th class="text">Remote</th><td>{{printf "%.2f" .RemoteTotal}}%</td><td>{{printf "%.2f" .RemoteUser}}%</td><td>{{printf "%.2f" .RemoteSystem}}%</td></tr>
+</table>
+{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+func WriteHTMLReport(w io.Writer, summaries []*RunSummary) error {
+	page := reportPage{
+		Title:   "IPerf3 Report",
+		Version: VersionGet(),
+		Created: GetTimestamp(),
+		Runs:    make([]reportRun, 0, len(summaries)),
+	}
+
+	for i, summary := range summaries {
+		page.Runs = append(page.Runs, reportRun{
+			Index:   i + 1,
+			Result:  summary.Result(),
+			Summary: summary,
+			Chart:   ThroughputChart(summary.Result()),
+		})
+	}
+
+	return reportTemplate.Execute(w, page)
+}
+
+func ExportHTMLReport(summaries []*RunSummary, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	err = WriteHTMLReport(file, summaries)
+	if err != nil {
+		return err
+	}
+
+	logs.Info("export html report %s with %d runs", path, len(summaries))
+	return nil
+}
+
+func htmlFilePath(jsonFile string) string {
+	return strings.TrimSuffix(jsonFile, filepath.Ext(jsonFile)) + ".html"
+}
@@ -0,0 +1,34 @@
+package iperf3
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestHTMLReportServerView(t *testing.T) {
+	server := bytes.Buffer{}
+	if err := json.Compact(&server, []byte(fakeServerOutput("127.0.0.1"))); err != nil {
+		t.Fatalf("server fixture invalid, %s", err.Error())
+	}
+	text := append(fakeClientOutput(3, 1e9), []byte(`{"event":"server_output_json","data":`+server.String()+"}\n")...)
+
+	summary, err := ParseResult(text, "")
+	if err != nil {
+		t.Fatalf("parse result fail, %s", err.Error())
+	}
+	if len(summary.Disagreements) == 0 {
+		t.Fatal("client 1Gbps and server 940Mbps, want a disagreement")
+	}
+
+	output := bytes.Buffer{}
+	if err := WriteHTMLReport(&output, []*RunSummary{summary}); err != nil {
+		t.Fatalf("write html report fail, %s", err.Error())
+	}
+	for _, want := range []string{"Client and Server View", "Client and Server Disagreements", summary.Disagreements[0]} {
+		if !strings.Contains(output.String(), want) {
+			t.Errorf("html report has no %q", want)
+		}
+	}
+}
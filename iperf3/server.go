@@ -12,7 +12,7 @@ import (
 )
 
 var serverWindow *walk.MainWindow
-var serverInstance map[int]*IperfServer
+var serverSupervisor *ServerSupervisor
 var serverMutex sync.Mutex
 var serverActive, serverFolderBut *walk.PushButton
 var serverStatusBar, serverFlowBar *walk.StatusBarItem
@@ -74,20 +74,42 @@ func ServerStatusUpdate(value string) {
 	}
 }
 
+func ServerFlowUpdate(value string, detail string) {
+	if serverWindow == nil || serverFlowBar == nil {
+		return
+	}
+	serverWindow.Synchronize(func() {
+		if serverFlowBar != nil {
+			serverFlowBar.SetText(value)
+			serverFlowBar.SetToolTipText(detail)
+		}
+	})
+}
+
+func ServerStatusSync() {
+	if serverWindow != nil {
+		serverWindow.Synchronize(func() {
+			serverMutex.Lock()
+			defer serverMutex.Unlock()
+			ServerStatus(ServerRunning())
+		})
+	}
+}
+
 func ServerRunning() bool {
-	return serverInstance != nil
+	return serverSupervisor != nil && serverSupervisor.Running()
 }
 
 func ServerStart() error {
-	serverInstance = make(map[int]*IperfServer)
-	for i := 0; i < configCache.ServerCount; i++ {
-		server, err := ServerStartup(i)
-		if err != nil {
-			logs.Warning("iperf server startup failed, %s", err.Error())
-			return err
-		}
-		serverInstance[i] = server
+	ServerShutdown()
+
+	supervisor := NewServerSupervisor(EngineGet(configCache), configCache)
+	err := supervisor.Start()
+	if err != nil {
+		logs.Warning("iperf server startup failed, %s", err.Error())
+		return err
 	}
+	serverSupervisor = supervisor
 	return nil
 }
 
@@ -113,14 +135,10 @@ func ServerStatus(flag bool) {
 }
 
 func ServerShutdown() error {
-	if serverInstance != nil {
-		for i, server := range serverInstance {
-			if server != nil {
-				server.Shutdown()
-				logs.Info("iperf3.exe index: %d shutdown", i)
-			}
-		}
-		serverInstance = nil
+	if serverSupervisor != nil {
+		serverSupervisor.Shutdown()
+		serverSupervisor = nil
+		ServerFlowUpdate("", "")
 	}
 	return nil
 }
@@ -0,0 +1,100 @@
+package iperf3
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const ServerDisagreePercent = 5.0
+
+type ServerViewRow struct {
+	Direction string
+	Metric    string
+	Client    string
+	Server    string
+	Delta     float64
+	Disagree  bool
+}
+
+type serverViewRow struct {
+	direction string
+	metric    string
+	client    float64
+	server    float64
+	bitrate   bool
+}
+
+func deltaPercent(base, value float64) float64 {
+	if base == 0 {
+		return 0
+	}
+	return (value - base) * 100 / base
+}
+
+func directionRows(direction string, client, server *DirectionSummary) []serverViewRow {
+	if client == nil || server == nil {
+		return nil
+	}
+	return []serverViewRow{
+		{direction, "sent bytes", float64(client.SentBytes), float64(server.SentBytes), false},
+		{direction, "received bytes", float64(client.ReceivedBytes), float64(server.ReceivedBytes), false},
+		{direction, "sent rate", client.SentBitsPerSecond, server.SentBitsPerSecond, true},
+		{direction, "received rate", client.ReceivedBitsPerSecond, server.ReceivedBitsPerSecond, true},
+	}
+}
+
+func (s *RunSummary) serverViewRows() []serverViewRow {
+	if s.Server == nil {
+		return nil
+	}
+	rows := directionRows(DirectionUpload, s.Upload, s.Server.Upload)
+	rows = append(rows, directionRows(DirectionDownload, s.Download, s.Server.Download)...)
+	return rows
+}
+
+func (s *RunSummary) serverDisagreements() []string {
+	output := make([]string, 0)
+	for _, row := range s.serverViewRows() {
+		delta := deltaPercent(row.client, row.server)
+		if math.Abs(delta) < ServerDisagreePercent {
+			continue
+		}
+		output = append(output, fmt.Sprintf("%s %s client %s server %s (%+.2f%%)",
+			row.direction, row.metric, row.view(row.client), row.view(row.server), delta))
+	}
+	return output
+}
+
+func (row serverViewRow) view(value float64) string {
+	if row.bitrate {
+		return BitRateView(value)
+	}
+	return ByteView(int64(value))
+}
+
+func (s *RunSummary) ServerView() string {
+	builder := strings.Builder{}
+	fmt.Fprintf(&builder, "%-10s %-16s %14s %14s %10s\n", "direction", "metric", "client", "server", "delta")
+	for _, row := range s.serverViewRows() {
+		fmt.Fprintf(&builder, "%-10s %-16s %14s %14s %+9.2f%%\n",
+			row.direction, row.metric, row.view(row.client), row.view(row.server), deltaPercent(row.client, row.server))
+	}
+	return builder.String()
+}
+
+func (s *RunSummary) ServerViewRows() []ServerViewRow {
+	output := make([]ServerViewRow, 0)
+	for _, row := range s.serverViewRows() {
+		delta := deltaPercent(row.client, row.server)
+		output = append(output, ServerViewRow{
+			Direction: row.direction,
+			Metric:    row.metric,
+			Client:    row.view(row.client),
+			Server:    row.view(row.server),
+			Delta:     delta,
+			Disagree:  math.Abs(delta) >= ServerDisagreePercent,
+		})
+	}
+	return output
+}
@@ -0,0 +1,120 @@
+package iperf3
+
+import (
+	"fmt"
+
+	"github.com/astaxie/beego/logs"
+)
+
+const (
+	ExitPass            = 0
+	ExitThresholdFailed = 1
+	ExitRunFailed       = 2
+)
+
+type SlaViolation struct {
+	Rule    string  `json:"rule"`
+	Limit   float64 `json:"limit"`
+	Value   float64 `json:"value"`
+	Message string  `json:"message"`
+}
+
+type SlaVerdict struct {
+	Pass       bool           `json:"pass"`
+	Violations []SlaViolation `json:"violations,omitempty"`
+}
+
+func (v *SlaVerdict) violate(rule string, limit, value float64, format string, args ...interface{}) {
+	v.Pass = false
+	v.Violations = append(v.Violations, SlaViolation{
+		Rule:    rule,
+		Limit:   limit,
+		Value:   value,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func ThresholdEnabled(config Config) bool {
+	return config.ThresholdMinThroughput > 0 || config.ThresholdMaxLoss > 0 ||
+		config.ThresholdMaxJitter > 0 || config.ThresholdMaxRetransmits > 0 || config.ThresholdMaxCpu > 0
+}
+
+func EvaluateThresholds(config Config, summary *RunSummary) *SlaVerdict {
+	verdict := &SlaVerdict{Pass: true}
+
+	if summary.Failed() {
+		verdict.violate("error", 0, 0, "run failed, %s", summary.Error)
+		return verdict
+	}
+
+	if config.ThresholdMinThroughput > 0 {
+		limit := config.ThresholdMinThroughput * 1000 * 1000
+		for _, direction := range summary.Directions() {
+			if direction.ReceivedBitsPerSecond < limit {
+				verdict.violate("min_throughput", limit, direction.ReceivedBitsPerSecond, "%s %s below %s",
+					direction.label("throughput"), BitRateView(direction.ReceivedBitsPerSecond), BitRateView(limit))
+			}
+		}
+	}
+
+	if config.ThresholdMaxLoss > 0 && summary.IsUDP() && summary.LostPercent > config.ThresholdMaxLoss {
+		verdict.violate("max_loss", config.ThresholdMaxLoss, summary.LostPercent,
+			"loss %.2f%% above %.2f%%", summary.LostPercent, config.ThresholdMaxLoss)
+	}
+
+	if config.ThresholdMaxJitter > 0 && summary.IsUDP() && summary.JitterMs > config.ThresholdMaxJitter {
+		verdict.violate("max_jitter", config.ThresholdMaxJitter, summary.JitterMs,
+			"jitter %.3fms above %.3fms", summary.JitterMs, config.ThresholdMaxJitter)
+	}
+
+	if config.ThresholdMaxRetransmits > 0 && !summary.IsUDP() && summary.Retransmits > int64(config.ThresholdMaxRetransmits) {
+		verdict.violate("max_retransmits", float64(config.ThresholdMaxRetransmits), float64(summary.Retransmits),
+			"retransmits %d above %d", summary.Retransmits, config.ThresholdMaxRetransmits)
+	}
+
+	if config.ThresholdMaxCpu > 0 {
+		for _, item := range []struct {
+			name  string
+			value float64
+		}{{"host", summary.HostCpu}, {"remote", summary.RemoteCpu}} {
+			if item.value > config.ThresholdMaxCpu {
+				verdict.violate("max_cpu", config.ThresholdMaxCpu, item.value,
+					"%s cpu %.1f%% above %.1f%%", item.name, item.value, config.ThresholdMaxCpu)
+			}
+		}
+	}
+
+	return verdict
+}
+
+func thresholdEvaluate(config Config, summary *RunSummary) {
+	if summary == nil || !ThresholdEnabled(config) {
+		return
+	}
+
+	summary.Sla = EvaluateThresholds(config, summary)
+	if summary.Sla.Pass {
+		logs.Info("threshold verdict pass")
+		return
+	}
+	for _, violation := range summary.Sla.Violations {
+		logs.Warning("threshold violated, %s", violation.Message)
+	}
+}
+
+func headlessExitCode(summaries []*RunSummary, runs int, err error) int {
+	if err != nil || runs == 0 || len(summaries) < runs {
+		return ExitRunFailed
+	}
+
+	exitCode := ExitPass
+	for _, summary := range summaries {
+		if summary.Failed() {
+			return ExitRunFailed
+		}
+		if summary.Sla != nil && !summary.Sla.Pass {
+			exitCode = ExitThresholdFailed
+		}
+	}
+	return exitCode
+}
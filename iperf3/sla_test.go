@@ -0,0 +1,42 @@
+package iperf3
+
+import (
+	"strings"
+	"testing"
+)
+
+func bidirSummary(upload, download float64) *RunSummary {
+	return &RunSummary{
+		Protocol:              "tcp",
+		ReceivedBitsPerSecond: upload,
+		Upload:                &DirectionSummary{Direction: DirectionUpload, ReceivedBitsPerSecond: upload},
+		Download:              &DirectionSummary{Direction: DirectionDownload, ReceivedBitsPerSecond: download},
+	}
+}
+
+func TestThresholdBidirDownload(t *testing.T) {
+	config := configCache
+	config.ThresholdMinThroughput = 100
+
+	verdict := EvaluateThresholds(config, bidirSummary(900e6, 10e6))
+	if verdict.Pass || len(verdict.Violations) != 1 {
+		t.Fatalf("verdict %+v, want one download violation", verdict)
+	}
+	if !strings.HasPrefix(verdict.Violations[0].Message, "download throughput") {
+		t.Errorf("violation %q, want the download direction named", verdict.Violations[0].Message)
+	}
+}
+
+func TestBaselineBidirDownload(t *testing.T) {
+	config := configCache
+	config.BaselineThroughputTolerance = 10
+
+	baseline := &Baseline{Summary: bidirSummary(900e6, 900e6)}
+	verdict := baseline.Compare(config, bidirSummary(900e6, 100e6))
+	if verdict.Verdict != VerdictRegressed || len(verdict.Reasons) != 1 {
+		t.Fatalf("verdict %+v, want download regressed", verdict)
+	}
+	if !strings.HasPrefix(verdict.Reasons[0], "download throughput") {
+		t.Errorf("reason %q, want the download direction named", verdict.Reasons[0])
+	}
+}
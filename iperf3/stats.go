@@ -0,0 +1,208 @@
+package iperf3
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+)
+
+const (
+	StabilityStable   = "stable"
+	StabilityVariable = "variable"
+	StabilityUnstable = "unstable"
+	StabilityNoData   = "no_data"
+)
+
+const StableVariation = 0.05
+const VariableVariation = 0.15
+
+type Statistic struct {
+	Count  int     `json:"count"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	P95    float64 `json:"p95"`
+	StdDev float64 `json:"stddev"`
+}
+
+type SeriesReport struct {
+	Time        time.Time  `json:"time"`
+	Target      string     `json:"target"`
+	Protocol    string     `json:"protocol"`
+	Runs        int        `json:"runs"`
+	Failed      int        `json:"failed"`
+	Parsed      int        `json:"parsed"`
+	Throughput  Statistic  `json:"throughput"`
+	Upload      *Statistic `json:"upload,omitempty"`
+	Download    *Statistic `json:"download,omitempty"`
+	JitterMs    *Statistic `json:"jitter_ms,omitempty"`
+	LostPercent *Statistic `json:"lost_percent,omitempty"`
+	Variation   float64    `json:"coefficient_of_variation"`
+	Stability   string     `json:"stability"`
+}
+
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+func NewStatistic(values []float64) Statistic {
+	stat := Statistic{Count: len(values)}
+	if len(values) == 0 {
+		return stat
+	}
+
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+
+	var total float64
+	for _, v := range sorted {
+		total += v
+	}
+	stat.Min = sorted[0]
+	stat.Max = sorted[len(sorted)-1]
+	stat.Mean = total / float64(len(sorted))
+	stat.Median = percentile(sorted, 0.5)
+	stat.P95 = percentile(sorted, 0.95)
+
+	var variance float64
+	for _, v := range sorted {
+		variance += (v - stat.Mean) * (v - stat.Mean)
+	}
+	stat.StdDev = math.Sqrt(variance / float64(len(sorted)))
+
+	return stat
+}
+
+func (s Statistic) Variation() float64 {
+	if s.Mean == 0 {
+		return 0
+	}
+	return s.StdDev / s.Mean
+}
+
+func NewSeriesReport(config Config, records []RunRecord) *SeriesReport {
+	report := &SeriesReport{
+		Time:     time.Now(),
+		Target:   fmt.Sprintf("%s:%d", config.ClientAddress, config.ClientPort),
+		Protocol: config.ClientProtocol,
+		Runs:     len(records),
+	}
+
+	throughput := make([]float64, 0)
+	upload := make([]float64, 0)
+	download := make([]float64, 0)
+	jitter := make([]float64, 0)
+	lost := make([]float64, 0)
+
+	for _, record := range records {
+		summary := record.Summary
+		if record.Err != nil || (summary != nil && summary.Failed()) {
+			report.Failed++
+			continue
+		}
+		if summary == nil {
+			continue
+		}
+		throughput = append(throughput, summary.ReceivedBitsPerSecond)
+		if summary.Upload != nil && summary.Download != nil {
+			upload = append(upload, summary.Upload.ReceivedBitsPerSecond)
+			download = append(download, summary.Download.ReceivedBitsPerSecond)
+		}
+		if summary.IsUDP() {
+			jitter = append(jitter, summary.JitterMs)
+			lost = append(lost, summary.LostPercent)
+		}
+	}
+
+	report.Parsed = len(throughput)
+	if report.Parsed == 0 {
+		report.Stability = StabilityNoData
+		return report
+	}
+
+	report.Throughput = NewStatistic(throughput)
+
+	if len(jitter) > 0 {
+		stat := NewStatistic(jitter)
+		report.JitterMs = &stat
+		stat = NewStatistic(lost)
+		report.LostPercent = &stat
+	}
+
+	report.Variation = report.Throughput.Variation()
+	if len(upload) > 0 {
+		stat := NewStatistic(upload)
+		report.Upload = &stat
+		stat = NewStatistic(download)
+		report.Download = &stat
+		report.Variation = math.Max(report.Upload.Variation(), report.Download.Variation())
+	}
+
+	switch {
+	case report.Variation <= StableVariation:
+		report.Stability = StabilityStable
+	case report.Variation <= VariableVariation:
+		report.Stability = StabilityVariable
+	default:
+		report.Stability = StabilityUnstable
+	}
+
+	return report
+}
+
+func (r *SeriesReport) String() string {
+	if r.Parsed == 0 {
+		return fmt.Sprintf("No JSON data in series, enable json format for statistics Failed: %d/%d", r.Failed, r.Runs)
+	}
+	if r.Upload != nil && r.Download != nil {
+		return fmt.Sprintf("Mean Up: %s Down: %s CV: %.1f%% %s Failed: %d/%d",
+			BitRateView(r.Upload.Mean), BitRateView(r.Download.Mean), r.Variation*100, r.Stability, r.Failed, r.Runs)
+	}
+	return fmt.Sprintf("Mean: %s CV: %.1f%% %s Failed: %d/%d",
+		BitRateView(r.Throughput.Mean), r.Variation*100, r.Stability, r.Failed, r.Runs)
+}
+
+func seriesResults(summaries []*RunSummary) []*Result {
+	results := make([]*Result, 0)
+	for _, summary := range summaries {
+		if summary != nil && summary.Result() != nil {
+			results = append(results, summary.Result())
+		}
+	}
+	return results
+}
+
+func seriesSummaries(summaries []*RunSummary) []*RunSummary {
+	output := make([]*RunSummary, 0)
+	for _, summary := range summaries {
+		if summary != nil && summary.Result() != nil {
+			output = append(output, summary)
+		}
+	}
+	return output
+}
+
+func (r *SeriesReport) Save(outputDir string) error {
+	if outputDir == "" {
+		return nil
+	}
+	value, err := json.MarshalIndent(r, "", "    ")
+	if err != nil {
+		return err
+	}
+	file := filepath.Join(outputDir, fmt.Sprintf("iperf3_series_%s.json", GetTimestamp()))
+	logs.Info("iperf3 series report %s", file)
+	return SaveToFile(file, value)
+}
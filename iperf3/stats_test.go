@@ -0,0 +1,44 @@
+package iperf3
+
+import "testing"
+
+func TestSeriesReportWithoutJson(t *testing.T) {
+	records := []RunRecord{{Index: 1}, {Index: 2}, {Index: 3}}
+
+	report := NewSeriesReport(configCache, records)
+	if report.Failed != 0 || report.Parsed != 0 || report.Stability != StabilityNoData {
+		t.Fatalf("series %+v, want no failures and no data", report)
+	}
+}
+
+func TestSeriesReportFailures(t *testing.T) {
+	records := []RunRecord{
+		{Index: 1, Summary: &RunSummary{ReceivedBitsPerSecond: 1e9}},
+		{Index: 2, Err: &IperfError{Kind: ErrorUnreachable}},
+		{Index: 3, Summary: &RunSummary{ReceivedBitsPerSecond: 1e9}},
+		{Index: 4, Summary: &RunSummary{Error: "the server is busy"}},
+	}
+
+	report := NewSeriesReport(configCache, records)
+	if report.Runs != 4 || report.Failed != 2 || report.Parsed != 2 {
+		t.Fatalf("series runs %d failed %d parsed %d, want 4 2 2", report.Runs, report.Failed, report.Parsed)
+	}
+	if report.Throughput.Mean != 1e9 || report.Stability != StabilityStable {
+		t.Errorf("series mean %.0f %s, want 1e9 stable", report.Throughput.Mean, report.Stability)
+	}
+}
+
+func TestSeriesReportBidir(t *testing.T) {
+	records := []RunRecord{
+		{Index: 1, Summary: bidirSummary(900e6, 900e6)},
+		{Index: 2, Summary: bidirSummary(900e6, 100e6)},
+	}
+
+	report := NewSeriesReport(configCache, records)
+	if report.Download == nil || report.Download.Mean != 500e6 {
+		t.Fatalf("series download %+v, want mean 500Mbps", report.Download)
+	}
+	if report.Stability != StabilityUnstable {
+		t.Errorf("series %s, want unstable from the download direction", report.Stability)
+	}
+}
@@ -0,0 +1,384 @@
+package iperf3
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+)
+
+type IntervalEvent struct {
+	Index    int
+	Time     time.Time
+	Target   string
+	Protocol string
+	Interval Interval
+	Upload   *Sum
+	Download *Sum
+}
+
+type IntervalHandler func(event IntervalEvent)
+
+var intervalHandlers []IntervalHandler
+var intervalMutex sync.Mutex
+
+func IntervalSubscribe(handler IntervalHandler) {
+	intervalMutex.Lock()
+	defer intervalMutex.Unlock()
+
+	intervalHandlers = append(intervalHandlers, handler)
+}
+
+func intervalPublish(event IntervalEvent) {
+	intervalMutex.Lock()
+	handlers := intervalHandlers
+	intervalMutex.Unlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+func (e IntervalEvent) BitsPerSecond() float64 {
+	return e.Interval.Sum.BitPerSecond + e.Interval.SumBidirReverse.BitPerSecond
+}
+
+type lineWriter struct {
+	buffer []byte
+	line   func(string)
+}
+
+func newLineWriter(line func(string)) *lineWriter {
+	return &lineWriter{line: line}
+}
+
+func (w *lineWriter) Write(body []byte) (int, error) {
+	w.buffer = append(w.buffer, body...)
+	for {
+		index := bytes.IndexByte(w.buffer, '\n')
+		if index < 0 {
+			break
+		}
+		w.line(strings.TrimRight(string(w.buffer[:index]), "\r"))
+		w.buffer = w.buffer[index+1:]
+	}
+	return len(body), nil
+}
+
+func (w *lineWriter) Flush() {
+	if len(w.buffer) > 0 {
+		w.line(strings.TrimRight(string(w.buffer), "\r"))
+		w.buffer = nil
+	}
+}
+
+type jsonStreamEvent struct {
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+func isJSONStream(text []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(text), []byte(`{"event"`))
+}
+
+func JSONStreamAssemble(text []byte) ([]byte, error) {
+	top := make(map[string]json.RawMessage)
+	intervals := make([]json.RawMessage, 0)
+
+	lines := bytes.Split(bytes.TrimSpace(text), []byte("\n"))
+	for i, line := range lines {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var event jsonStreamEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			if i == len(lines)-1 && len(top) > 0 {
+				logs.Warning("json stream truncated, drop last line, %s", err.Error())
+				break
+			}
+			return nil, err
+		}
+		if event.Event == "interval" {
+			intervals = append(intervals, event.Data)
+		} else {
+			top[event.Event] = event.Data
+		}
+	}
+
+	value, err := json.Marshal(intervals)
+	if err != nil {
+		return nil, err
+	}
+	top["intervals"] = value
+
+	return json.Marshal(top)
+}
+
+type IntervalParser struct {
+	result     Result
+	current    *Interval
+	sumSeen    bool
+	reverseSum bool
+	index      int
+	handler    IntervalHandler
+}
+
+func NewIntervalParser(start Start, handler IntervalHandler) *IntervalParser {
+	return &IntervalParser{
+		result:  Result{Start: start},
+		handler: handler,
+	}
+}
+
+var textIntervalRegexp = regexp.MustCompile(
+	`^\[\s*(\d+|SUM)\](?:\[([TR]X)-[CS]\])?\s+([\d.]+)-([\d.]+)\s+sec\s+([\d.]+)\s+(\w?)Bytes\s+([\d.]+)\s+(\w?)bits/sec(.*)$`)
+
+var textUdpRegexp = regexp.MustCompile(`([\d.]+)\s+ms\s+(\d+)/(\d+)\s+\(([\d.e+-]+)%\)`)
+
+func unitScale(unit string, base float64) float64 {
+	switch unit {
+	case "K":
+		return base
+	case "M":
+		return base * base
+	case "G":
+		return base * base * base
+	case "T":
+		return base * base * base * base
+	}
+	return 1
+}
+
+func (p *IntervalParser) ParseLine(line string) {
+	if isJSONStream([]byte(line)) {
+		p.parseJSONLine(line)
+		return
+	}
+	p.parseTextLine(line)
+}
+
+func (p *IntervalParser) parseJSONLine(line string) {
+	var event jsonStreamEvent
+	if err := json.Unmarshal([]byte(line), &event); err != nil {
+		logs.Warning("json stream line invalid, %s", err.Error())
+		return
+	}
+
+	switch event.Event {
+	case "start":
+		if err := json.Unmarshal(event.Data, &p.result.Start); err != nil {
+			logs.Warning("json stream start invalid, %s", err.Error())
+		}
+	case "interval":
+		var interval Interval
+		if err := json.Unmarshal(event.Data, &interval); err != nil {
+			logs.Warning("json stream interval invalid, %s", err.Error())
+			return
+		}
+		p.emit(&interval)
+	}
+}
+
+func (p *IntervalParser) parseTextLine(line string) {
+	if strings.HasPrefix(line, "- - -") {
+		p.flush()
+		return
+	}
+
+	match := textIntervalRegexp.FindStringSubmatch(line)
+	if match == nil {
+		return
+	}
+
+	rest := match[9]
+	if strings.Contains(rest, "sender") || strings.Contains(rest, "receiver") {
+		p.flush()
+		return
+	}
+
+	start, _ := strconv.ParseFloat(match[3], 64)
+	end, _ := strconv.ParseFloat(match[4], 64)
+	size, _ := strconv.ParseFloat(match[5], 64)
+	rate, _ := strconv.ParseFloat(match[7], 64)
+
+	stream := Stream{
+		Start:        start,
+		End:          end,
+		Seconds:      end - start,
+		Bytes:        int64(size * unitScale(match[6], 1024)),
+		BitPerSecond: rate * unitScale(match[8], 1000),
+		Omitted:      strings.Contains(rest, "omitted"),
+		Sender:       match[2] != "RX",
+	}
+
+	if udp := textUdpRegexp.FindStringSubmatch(rest); udp != nil {
+		stream.JitterMs, _ = strconv.ParseFloat(udp[1], 64)
+		stream.LostPackets, _ = strconv.ParseInt(udp[2], 10, 64)
+		stream.Packets, _ = strconv.ParseInt(udp[3], 10, 64)
+		stream.LostPercent, _ = strconv.ParseFloat(udp[4], 64)
+	} else if fields := strings.Fields(rest); len(fields) > 0 && !p.result.IsUDP() {
+		stream.Retransmits, _ = strconv.ParseInt(fields[0], 10, 64)
+	} else if len(fields) > 0 {
+		stream.Packets, _ = strconv.ParseInt(fields[0], 10, 64)
+	}
+
+	if p.current != nil && (p.current.Sum.Start != start || p.current.Sum.End != end) {
+		p.flush()
+	}
+
+	if p.current == nil {
+		p.current = &Interval{Streams: make([]Stream, 0)}
+		p.current.Sum = Sum{Start: start, End: end, Seconds: end - start}
+		p.sumSeen = false
+		p.reverseSum = false
+	}
+
+	if match[1] == "SUM" {
+		sum := Sum{
+			Start:        stream.Start,
+			End:          stream.End,
+			Seconds:      stream.Seconds,
+			Bytes:        stream.Bytes,
+			BitPerSecond: stream.BitPerSecond,
+			Omitted:      stream.Omitted,
+			JitterMs:     stream.JitterMs,
+			LostPackets:  stream.LostPackets,
+			Packets:      stream.Packets,
+			LostPercent:  stream.LostPercent,
+			Retransmits:  stream.Retransmits,
+			Sender:       stream.Sender,
+		}
+		if p.isReverse(stream) {
+			p.current.SumBidirReverse = sum
+			p.reverseSum = true
+		} else {
+			p.current.Sum = sum
+			p.sumSeen = true
+		}
+	} else {
+		stream.Socket, _ = strconv.ParseInt(match[1], 10, 64)
+		p.current.Streams = append(p.current.Streams, stream)
+	}
+
+	if p.complete() {
+		p.flush()
+	}
+}
+
+func (p *IntervalParser) isReverse(stream Stream) bool {
+	send, _ := p.result.localDirections()
+	return p.result.IsBidir() && p.result.streamDirection(stream.Sender) != send
+}
+
+func (p *IntervalParser) complete() bool {
+	streams := int(p.result.Start.TestStart.NumStreams)
+	if p.current == nil || streams <= 0 {
+		return false
+	}
+
+	directions := 1
+	if p.result.IsBidir() {
+		directions = 2
+	}
+
+	if streams == 1 {
+		return len(p.current.Streams) >= directions
+	}
+
+	sums := 0
+	if p.sumSeen {
+		sums++
+	}
+	if p.reverseSum {
+		sums++
+	}
+	return sums >= directions
+}
+
+func streamSum(start, end float64, streams []Stream) Sum {
+	sum := Sum{Start: start, End: end, Seconds: end - start}
+	jitter := 0.0
+	for i, stream := range streams {
+		sum.Bytes += stream.Bytes
+		sum.BitPerSecond += stream.BitPerSecond
+		sum.Retransmits += stream.Retransmits
+		sum.LostPackets += stream.LostPackets
+		sum.Packets += stream.Packets
+		sum.Omitted = sum.Omitted || stream.Omitted
+		jitter += stream.JitterMs
+		if i == 0 {
+			sum.Sender = stream.Sender
+		}
+	}
+	sum.JitterMs = jitter / float64(len(streams))
+	if sum.Packets > 0 {
+		sum.LostPercent = float64(sum.LostPackets) * 100 / float64(sum.Packets)
+	}
+	return sum
+}
+
+func (p *IntervalParser) flush() {
+	if p.current == nil {
+		return
+	}
+	interval := p.current
+	p.current = nil
+
+	forward := make([]Stream, 0)
+	reverse := make([]Stream, 0)
+	for _, stream := range interval.Streams {
+		if p.isReverse(stream) {
+			reverse = append(reverse, stream)
+		} else {
+			forward = append(forward, stream)
+		}
+	}
+
+	start, end := interval.Sum.Start, interval.Sum.End
+	if !p.sumSeen && len(forward) > 0 {
+		interval.Sum = streamSum(start, end, forward)
+	}
+	if !p.reverseSum && len(reverse) > 0 {
+		interval.SumBidirReverse = streamSum(start, end, reverse)
+	}
+
+	p.emit(interval)
+}
+
+func (p *IntervalParser) emit(interval *Interval) {
+	for i := range interval.Streams {
+		interval.Streams[i].Direction = p.result.streamDirection(interval.Streams[i].Sender)
+	}
+
+	p.index++
+
+	event := IntervalEvent{
+		Index:    p.index,
+		Time:     time.Now(),
+		Target:   fmt.Sprintf("%s:%d", p.result.Start.ConnectingTo.Host, p.result.Start.ConnectingTo.Port),
+		Protocol: strings.ToLower(p.result.Start.TestStart.Protocol),
+		Interval: *interval,
+	}
+
+	if sum, ok := p.result.IntervalSum(interval, DirectionUpload); ok {
+		event.Upload = &sum
+	}
+	if sum, ok := p.result.IntervalSum(interval, DirectionDownload); ok {
+		event.Download = &sum
+	}
+
+	if p.handler != nil {
+		p.handler(event)
+	}
+}
+
+func (p *IntervalParser) Close() {
+	p.flush()
+}
@@ -0,0 +1,281 @@
+package iperf3
+
+import (
+	"fmt"
+	"strings"
+)
+
+type RunSummary struct {
+	Target                string            `json:"target"`
+	File                  string            `json:"file,omitempty"`
+	SentBytes             int64             `json:"sent_bytes"`
+	ReceivedBytes         int64             `json:"received_bytes"`
+	SentBitsPerSecond     float64           `json:"sent_bits_per_second"`
+	ReceivedBitsPerSecond float64           `json:"received_bits_per_second"`
+	Duration              float64           `json:"duration"`
+	HostCpu               float64           `json:"host_cpu"`
+	RemoteCpu             float64           `json:"remote_cpu"`
+	Streams               int64             `json:"streams"`
+	Protocol              string            `json:"protocol"`
+	JitterMs              float64           `json:"jitter_ms"`
+	LostPackets           int64             `json:"lost_packets"`
+	Packets               int64             `json:"packets"`
+	LostPercent           float64           `json:"lost_percent"`
+	OutOfOrder            int64             `json:"out_of_order"`
+	Retransmits           int64             `json:"retransmits"`
+	RetransmitPercent     float64           `json:"retransmit_percent"`
+	RetransmitHeavy       bool              `json:"retransmit_heavy"`
+	MaxRtt                int64             `json:"max_rtt"`
+	MinRtt                int64             `json:"min_rtt"`
+	MeanRtt               int64             `json:"mean_rtt"`
+	MaxSndCwnd            int64             `json:"max_snd_cwnd"`
+	Upload                *DirectionSummary `json:"upload,omitempty"`
+	Download              *DirectionSummary `json:"download,omitempty"`
+	Server                *RunSummary       `json:"server,omitempty"`
+	Disagreements         []string          `json:"disagreements,omitempty"`
+	Error                 string            `json:"error"`
+	ErrorKind             ErrorKind         `json:"error_kind,omitempty"`
+	Interrupted           bool              `json:"interrupted,omitempty"`
+	Baseline              *BaselineVerdict  `json:"baseline,omitempty"`
+	Sla                   *SlaVerdict       `json:"sla,omitempty"`
+	Fairness              []*FairnessReport `json:"fairness,omitempty"`
+	Anomalies             []Anomaly         `json:"anomalies,omitempty"`
+	CpuBound              *CpuBoundReport   `json:"cpu_bound,omitempty"`
+
+	result *Result
+}
+
+type DirectionSummary struct {
+	Direction             string  `json:"direction"`
+	SentBytes             int64   `json:"sent_bytes"`
+	ReceivedBytes         int64   `json:"received_bytes"`
+	SentBitsPerSecond     float64 `json:"sent_bits_per_second"`
+	ReceivedBitsPerSecond float64 `json:"received_bits_per_second"`
+	Retransmits           int64   `json:"retransmits"`
+	JitterMs              float64 `json:"jitter_ms"`
+	LostPercent           float64 `json:"lost_percent"`
+	Streams               int64   `json:"streams"`
+}
+
+const RetransmitHeavyPercent = 1.0
+const tcpDefaultMss = 1448
+
+func NewRunSummary(result *Result) *RunSummary {
+	summary := &RunSummary{
+		SentBytes:             result.End.SumSender.Bytes,
+		ReceivedBytes:         result.End.SumReceiver.Bytes,
+		SentBitsPerSecond:     result.End.SumSender.BitPerSecond,
+		ReceivedBitsPerSecond: result.End.SumReceiver.BitPerSecond,
+		Duration:              result.End.SumReceiver.Seconds,
+		HostCpu:               result.End.CpuPercent.HostTotal,
+		RemoteCpu:             result.End.CpuPercent.RemoteTotal,
+		Streams:               result.Start.TestStart.NumStreams,
+		Protocol:              strings.ToLower(result.Start.TestStart.Protocol),
+		Error:                 result.Error,
+		result:                result,
+	}
+
+	if result.IsUDP() {
+		summary.udpSummary(result)
+	} else {
+		summary.tcpSummary(result)
+	}
+
+	summary.Upload = newDirectionSummary(result, DirectionUpload)
+	summary.Download = newDirectionSummary(result, DirectionDownload)
+
+	if fairness := NewFairnessReports(result); len(fairness) > 0 {
+		summary.Fairness = fairness
+	}
+
+	if result.ServerOutputJson != nil {
+		summary.Server = NewRunSummary(result.ServerOutputJson)
+		summary.Disagreements = summary.serverDisagreements()
+	}
+
+	if result.IsClientSide() {
+		summary.Target = fmt.Sprintf("%s:%d", result.Start.ConnectingTo.Host, result.Start.ConnectingTo.Port)
+	} else if len(result.Start.Connected) > 0 {
+		summary.Target = result.Start.Connected[0].RemoteHost
+	}
+
+	if summary.Error != "" {
+		summary.ErrorKind = ClassifyError(summary.Error)
+		summary.Interrupted = summary.ErrorKind == ErrorInterrupted &&
+			(summary.SentBytes > 0 || summary.ReceivedBytes > 0)
+	}
+
+	if summary.Duration == 0 {
+		summary.Duration = result.End.SumSender.Seconds
+	}
+
+	if summary.Streams == 0 {
+		summary.Streams = int64(len(result.End.Streams))
+	}
+
+	return summary
+}
+
+func newDirectionSummary(result *Result, direction string) *DirectionSummary {
+	sender, receiver, ok := result.SumsByDirection(direction)
+	if !ok {
+		return nil
+	}
+
+	summary := &DirectionSummary{
+		Direction:             direction,
+		SentBytes:             sender.Bytes,
+		ReceivedBytes:         receiver.Bytes,
+		SentBitsPerSecond:     sender.BitPerSecond,
+		ReceivedBitsPerSecond: receiver.BitPerSecond,
+		Retransmits:           sender.Retransmits,
+		JitterMs:              receiver.JitterMs,
+		LostPercent:           receiver.LostPercent,
+	}
+
+	for _, stream := range result.End.Streams {
+		if stream.Sender.Direction == direction {
+			summary.Streams++
+		}
+	}
+
+	return summary
+}
+
+func (s *RunSummary) udpSummary(result *Result) {
+	sum := result.End.Sum
+	if sum.Packets == 0 {
+		sum = result.End.SumReceiver
+	}
+
+	s.JitterMs = sum.JitterMs
+	s.LostPackets = sum.LostPackets
+	s.Packets = sum.Packets
+	s.LostPercent = sum.LostPercent
+
+	if s.LostPercent == 0 && s.Packets > 0 {
+		s.LostPercent = float64(s.LostPackets) * 100 / float64(s.Packets)
+	}
+
+	for _, stream := range result.End.Streams {
+		s.OutOfOrder += stream.Udp.OutOfOrder
+	}
+}
+
+func (s *RunSummary) tcpSummary(result *Result) {
+	s.Retransmits = result.End.SumSender.Retransmits
+
+	var meanTotal, meanCount int64
+	for _, stream := range result.End.Streams {
+		sender := stream.Sender
+		if sender.MaxRtt > s.MaxRtt {
+			s.MaxRtt = sender.MaxRtt
+		}
+		if sender.MinRtt > 0 && (s.MinRtt == 0 || sender.MinRtt < s.MinRtt) {
+			s.MinRtt = sender.MinRtt
+		}
+		if sender.MeanRtt > 0 {
+			meanTotal += sender.MeanRtt
+			meanCount++
+		}
+		if sender.MaxSndCwnd > s.MaxSndCwnd {
+			s.MaxSndCwnd = sender.MaxSndCwnd
+		}
+	}
+	if meanCount > 0 {
+		s.MeanRtt = meanTotal / meanCount
+	}
+
+	mss := result.Start.TcpMssDefault
+	if mss <= 0 {
+		mss = tcpDefaultMss
+	}
+
+	segments := s.SentBytes / mss
+	if segments > 0 {
+		s.RetransmitPercent = float64(s.Retransmits) * 100 / float64(segments)
+	}
+	s.RetransmitHeavy = s.RetransmitPercent >= RetransmitHeavyPercent
+}
+
+func (s *RunSummary) IsUDP() bool {
+	return s.Protocol == "udp"
+}
+
+func (s *RunSummary) Result() *Result {
+	return s.result
+}
+
+// Directions lists each measured direction of the run, a run without
+// direction labels is returned as a single unnamed direction.
+func (s *RunSummary) Directions() []*DirectionSummary {
+	directions := make([]*DirectionSummary, 0, 2)
+	for _, direction := range []*DirectionSummary{s.Upload, s.Download} {
+		if direction != nil {
+			directions = append(directions, direction)
+		}
+	}
+	if len(directions) == 0 {
+		directions = append(directions, &DirectionSummary{
+			SentBytes:             s.SentBytes,
+			ReceivedBytes:         s.ReceivedBytes,
+			SentBitsPerSecond:     s.SentBitsPerSecond,
+			ReceivedBitsPerSecond: s.ReceivedBitsPerSecond,
+			Retransmits:           s.Retransmits,
+			JitterMs:              s.JitterMs,
+			LostPercent:           s.LostPercent,
+			Streams:               s.Streams,
+		})
+	}
+	return directions
+}
+
+func (d *DirectionSummary) label(name string) string {
+	if d.Direction == "" {
+		return name
+	}
+	return d.Direction + " " + name
+}
+
+func (s *RunSummary) Failed() bool {
+	return s.Error != ""
+}
+
+func (s *RunSummary) String() string {
+	if s.Failed() && !s.Interrupted {
+		return "Error: " + s.Error
+	}
+
+	builder := strings.Builder{}
+	if s.Upload != nil && s.Download != nil {
+		fmt.Fprintf(&builder, "Up: %s", BitRateView(s.Upload.ReceivedBitsPerSecond))
+		fmt.Fprintf(&builder, " Down: %s", BitRateView(s.Download.ReceivedBitsPerSecond))
+	} else {
+		if s.Download != nil {
+			builder.WriteString("Down ")
+		}
+		fmt.Fprintf(&builder, "Send: %s", BitRateView(s.SentBitsPerSecond))
+		fmt.Fprintf(&builder, " Recv: %s", BitRateView(s.ReceivedBitsPerSecond))
+	}
+	if s.IsUDP() {
+		fmt.Fprintf(&builder, " Jitter: %.3fms Loss: %.2f%%", s.JitterMs, s.LostPercent)
+	} else {
+		fmt.Fprintf(&builder, " Retr: %d", s.Retransmits)
+		if s.RetransmitHeavy {
+			fmt.Fprintf(&builder, " (%.2f%% heavy)", s.RetransmitPercent)
+		}
+	}
+	if s.Sla != nil && !s.Sla.Pass {
+		fmt.Fprintf(&builder, " [SLA fail %d]", len(s.Sla.Violations))
+	}
+	if s.Baseline != nil {
+		fmt.Fprintf(&builder, " [%s %+.1f%%]", s.Baseline.Verdict, s.Baseline.ThroughputDelta)
+	}
+	if s.CpuBound != nil {
+		builder.WriteString(" [cpu-bound]")
+	}
+	if s.Interrupted {
+		builder.WriteString(" [interrupted]")
+	}
+	return builder.String()
+}
@@ -0,0 +1,282 @@
+package iperf3
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+)
+
+const (
+	ServerStateUp         = "up"
+	ServerStateRestarting = "restarting"
+	ServerStateFailed     = "failed"
+	ServerStateStopped    = "stopped"
+)
+
+const (
+	SupervisorBackoffMin    = time.Second
+	SupervisorBackoffMax    = time.Minute
+	SupervisorStableUptime  = time.Minute
+	SupervisorRestartLimit  = 5
+	SupervisorRestartWindow = 5 * time.Minute
+)
+
+type ServerSlot struct {
+	Index    int       `json:"index"`
+	Target   string    `json:"target"`
+	State    string    `json:"state"`
+	Restarts int       `json:"restarts"`
+	Error    string    `json:"error,omitempty"`
+	Since    time.Time `json:"since"`
+}
+
+type serverSlot struct {
+	ServerSlot
+	instance *IperfServer
+	crashes  []time.Time
+	backoff  time.Duration
+}
+
+type ServerSupervisor struct {
+	mutex    sync.Mutex
+	engine   Engine
+	config   Config
+	slots    []*serverSlot
+	stop     chan struct{}
+	stopOnce sync.Once
+	wait     sync.WaitGroup
+}
+
+func NewServerSupervisor(engine Engine, config Config) *ServerSupervisor {
+	supervisor := &ServerSupervisor{
+		engine: engine,
+		config: config,
+		slots:  make([]*serverSlot, 0, config.ServerCount),
+		stop:   make(chan struct{}),
+	}
+	for i := 0; i < config.ServerCount; i++ {
+		supervisor.slots = append(supervisor.slots, &serverSlot{
+			ServerSlot: ServerSlot{
+				Index:  i,
+				Target: fmt.Sprintf("%s:%d", config.ServerListen, config.ServerPort+i),
+				State:  ServerStateStopped,
+				Since:  time.Now(),
+			},
+		})
+	}
+	return supervisor
+}
+
+func (s *ServerSupervisor) Start() error {
+	for _, slot := range s.slots {
+		instance, err := ServerStartup(s.engine, s.config, slot.Index)
+		if err != nil {
+			s.Shutdown()
+			return err
+		}
+		s.up(slot, instance)
+	}
+
+	for _, slot := range s.slots {
+		s.wait.Add(1)
+		go s.watch(slot, slot.instance)
+	}
+
+	s.report()
+	return nil
+}
+
+func (s *ServerSupervisor) stopping() bool {
+	select {
+	case <-s.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *ServerSupervisor) up(slot *serverSlot, instance *IperfServer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	slot.instance = instance
+	slot.State = ServerStateUp
+	slot.Error = ""
+	slot.Since = time.Now()
+}
+
+func (s *ServerSupervisor) crashed(slot *serverSlot, message string) (time.Duration, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	if slot.State == ServerStateUp && now.Sub(slot.Since) >= SupervisorStableUptime {
+		slot.backoff = 0
+	}
+
+	crashes := make([]time.Time, 0, len(slot.crashes)+1)
+	for _, crash := range slot.crashes {
+		if now.Sub(crash) < SupervisorRestartWindow {
+			crashes = append(crashes, crash)
+		}
+	}
+	slot.crashes = append(crashes, now)
+
+	slot.instance = nil
+	slot.Error = message
+	slot.Since = now
+
+	if len(slot.crashes) > SupervisorRestartLimit {
+		slot.State = ServerStateFailed
+		return 0, false
+	}
+
+	if slot.backoff == 0 {
+		slot.backoff = SupervisorBackoffMin
+	} else {
+		slot.backoff *= 2
+		if slot.backoff > SupervisorBackoffMax {
+			slot.backoff = SupervisorBackoffMax
+		}
+	}
+
+	slot.State = ServerStateRestarting
+	slot.Restarts++
+
+	return slot.backoff, true
+}
+
+func (s *ServerSupervisor) watch(slot *serverSlot, instance *IperfServer) {
+	defer s.wait.Done()
+
+	message := ""
+	for {
+		if instance != nil {
+			select {
+			case <-instance.Done():
+			case <-s.stop:
+				return
+			}
+			if s.stopping() {
+				return
+			}
+			_, runErr := instance.Wait()
+			message = fmt.Sprintf("exit code %d", instance.ExitCode())
+			if runErr != nil {
+				message = runErr.Error()
+			}
+		}
+
+		logs.Warning("iperf3 server %s down, %s", slot.Target, message)
+
+		backoff, retry := s.crashed(slot, message)
+		s.report()
+		if !retry {
+			logs.Error("iperf3 server %s crashed %d times in %s, give up",
+				slot.Target, SupervisorRestartLimit+1, SupervisorRestartWindow)
+			return
+		}
+
+		logs.Info("iperf3 server %s restart in %s", slot.Target, backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-s.stop:
+			return
+		}
+
+		var err error
+		instance, err = ServerStartup(s.engine, s.config, slot.Index)
+		if err != nil {
+			message = err.Error()
+			continue
+		}
+		if s.stopping() {
+			instance.Shutdown()
+			return
+		}
+
+		s.up(slot, instance)
+		s.report()
+	}
+}
+
+func (s *ServerSupervisor) Shutdown() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	s.wait.Wait()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, slot := range s.slots {
+		if slot.instance != nil {
+			slot.instance.Shutdown()
+			logs.Info("iperf3 server %s shutdown", slot.Target)
+		}
+		slot.instance = nil
+		slot.State = ServerStateStopped
+		slot.Since = time.Now()
+	}
+}
+
+func (s *ServerSupervisor) Running() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, slot := range s.slots {
+		if slot.State == ServerStateUp || slot.State == ServerStateRestarting {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *ServerSupervisor) Slots() []ServerSlot {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	slots := make([]ServerSlot, 0, len(s.slots))
+	for _, slot := range s.slots {
+		slots = append(slots, slot.ServerSlot)
+	}
+	return slots
+}
+
+func (s *ServerSupervisor) String() string {
+	counts := make(map[string]int)
+	for _, slot := range s.Slots() {
+		counts[slot.State]++
+	}
+	return fmt.Sprintf("Up %d Restart %d Failed %d",
+		counts[ServerStateUp], counts[ServerStateRestarting], counts[ServerStateFailed])
+}
+
+func (s *ServerSupervisor) Detail() string {
+	lines := make([]string, 0)
+	for _, slot := range s.Slots() {
+		line := fmt.Sprintf("%s %s", slot.Target, slot.State)
+		if slot.Restarts > 0 {
+			line += fmt.Sprintf(" restarts %d", slot.Restarts)
+		}
+		if slot.State != ServerStateUp && slot.Error != "" {
+			line += ", " + slot.Error
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (s *ServerSupervisor) report() {
+	logs.Info("iperf3 server pool %s", s.String())
+
+	ServerFlowUpdate(s.String(), s.Detail())
+
+	if !s.Running() && !s.stopping() {
+		ServerStatusSync()
+	}
+}
@@ -47,6 +47,18 @@ func ByteView(size int64) string {
 	}
 }
 
+func BitRateView(bps float64) string {
+	if bps < 1000 {
+		return fmt.Sprintf("%.0fbps", bps)
+	} else if bps < (1000 * 1000) {
+		return fmt.Sprintf("%.1fKbps", bps/1000)
+	} else if bps < (1000 * 1000 * 1000) {
+		return fmt.Sprintf("%.1fMbps", bps/(1000*1000))
+	} else {
+		return fmt.Sprintf("%.1fGbps", bps/(1000*1000*1000))
+	}
+}
+
 func InterfaceGet(iface *net.Interface) ([]net.IP, error) {
 	addrs, err := iface.Addrs()
 	if err != nil {
@@ -121,6 +133,10 @@ func GetTimestamp() string {
 	return time.Now().Format("2006-01-02T15-04-05")
 }
 
+func GetTimestampMicro() string {
+	return time.Now().Format("2006-01-02T15-04-05.000000")
+}
+
 func GetTimestampUS() int64 {
 	return time.Now().UnixNano()
 }
@@ -0,0 +1,64 @@
+package iperf3
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+)
+
+type Watchdog struct {
+	deadline time.Duration
+	timer    *time.Timer
+	expired  atomic.Bool
+}
+
+func clientDeadline(config Config) time.Duration {
+	if config.ClientRunTime <= 0 {
+		return 0
+	}
+	seconds := config.ClientRunTime + config.ClientOmitSec + config.ClientWatchdogAllowance
+	return time.Duration(seconds) * time.Second
+}
+
+func NewWatchdog(deadline time.Duration, handle RunHandle) *Watchdog {
+	watchdog := &Watchdog{deadline: deadline}
+	if deadline <= 0 {
+		return watchdog
+	}
+	watchdog.timer = time.AfterFunc(deadline, func() {
+		watchdog.expired.Store(true)
+		logs.Warning("iperf3 run over deadline %s, stop it", deadline)
+		handle.Stop()
+	})
+	return watchdog
+}
+
+func (w *Watchdog) Stop() {
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+}
+
+func (w *Watchdog) Expired() bool {
+	return w.expired.Load()
+}
+
+func (w *Watchdog) apply(summary *RunSummary, runErr *IperfError, exitCode int) *IperfError {
+	if !w.Expired() {
+		return runErr
+	}
+
+	timeout := &IperfError{
+		Kind:     ErrorTimeout,
+		Message:  fmt.Sprintf("iperf3 run timed out after %s", w.deadline),
+		ExitCode: exitCode,
+	}
+	if summary != nil {
+		summary.Error = timeout.Message
+		summary.ErrorKind = ErrorTimeout
+		summary.Interrupted = false
+	}
+	return timeout
+}
@@ -10,5 +10,7 @@ func main() {
 	iperf.LogInit(NAME)
 	iperf.IconInit()
 	iperf.ConfigInit(NAME)
+	iperf.MetricsInit()
+	iperf.InfluxInit()
 	iperf.ServerWindows()
 }